@@ -17,62 +17,167 @@ limitations under the License.
 package dbi
 
 import (
+	"context"
 	"errors"
 	"fmt"
-
-	_ "github.com/go-sql-driver/mysql"
-	_ "github.com/lib/pq"
+	"net"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/parser"
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/redact"
 )
 
-// defaultPort specifies the TCP/IP port on which sql driver is listening
-// for connections from client applications
-var defaultPort = map[string]string{
-	"mysql":    "3306",
-	"postgres": "5432",
+// getDefaultPort returns default port for driver of database, empty for drivers which do not use TCP port
+func getDefaultPort(db *dtype.Database) string {
+	d, ok := getDriver(db.Driver)
+	if !ok || d.DefaultPort == nil {
+		return ""
+	}
+	return d.DefaultPort(db)
 }
 
-// getDefaultPort returns default port for specific driver
-func getDefaultPort(driver string) string {
-	return defaultPort[driver]
+// usesTCPPort returns false if connection to database is established without TCP port given explicitly,
+// i.e. for Unix domain socket connections and SQL Server named instances (their port is resolved
+// by SQL Server Browser); file-based drivers have no default port
+func usesTCPPort(db *dtype.Database) bool {
+	return isEmpty(db.Socket) && isEmpty(db.Instance)
 }
 
-// openDB opens a database and verifies connection by calling ping to it
+// openDB opens a database, if hosts of cluster are given it fails over to the next host
+// until the one matching host policy is connected
 func openDB(db *dtype.Database) error {
-	var dsn string
+	if len(db.Hosts) == 0 {
+		return openHost(db)
+	}
 
-	// if port is not defined, set defaults
-	if isEmpty(db.Port) {
-		db.Port = getDefaultPort(db.Driver)
+	failed := []string{}
+	// primary host which is used when no replica is reachable (prefer-replica policy)
+	fallback := ""
+
+	for _, host := range db.Hosts {
+		setHost(db, host)
+		if err := openHost(db); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", host, err))
+			continue
+		}
+
+		replica := false
+		if db.Policy != dtype.HostPolicyAny {
+			var err error
+			if replica, err = isReplica(db); err != nil {
+				closeDB(db)
+				failed = append(failed, fmt.Sprintf("%s: %v", host, err))
+				continue
+			}
+		}
+
+		if db.Policy == dtype.HostPolicyAny ||
+			(db.Policy == dtype.HostPolicyPrimaryOnly && !replica) ||
+			(db.Policy == dtype.HostPolicyPreferReplica && replica) {
+			db.Connected = host
+			return nil
+		}
+
+		if !replica && fallback == "" {
+			fallback = host
+		}
+		closeDB(db)
+		failed = append(failed, fmt.Sprintf("%s: does not match host policy", host))
 	}
 
-	switch db.Driver {
-	case "postgres":
-		dsn = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-			db.Username, db.Password, db.Host, db.Port, db.DBName)
+	if fallback != "" {
+		setHost(db, fallback)
+		err := openHost(db)
+		if err == nil {
+			db.Connected = fallback
+			return nil
+		}
+		failed = append(failed, fmt.Sprintf("%s: %v", fallback, err))
+	}
 
-	case "mysql":
-		dsn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s",
-			db.Username, db.Password, db.Host, db.Port, db.DBName)
+	db.Connected = ""
+	return fmt.Errorf("Cannot connect to any of hosts (policy %s): %s", db.Policy, strings.Join(failed, "; "))
+}
+
+// setHost sets host and port of database to the ones of cluster host ("host" or "host:port"),
+// the default port is used if it is not given
+func setHost(db *dtype.Database, host string) {
+	h, p, err := net.SplitHostPort(host)
+	if err != nil {
+		h, p = host, ""
+	}
+	db.Host, db.Port = h, p
+}
+
+// isReplica returns true if database server is a replica of cluster
+func isReplica(db *dtype.Database) (bool, error) {
+	ctx, cancel := withTimeout(db.Timeout)
+	defer cancel()
+	return db.Executor.IsReplica(ctx)
+}
+
+// openHost opens a database and verifies connection by calling ping to it, when authentication fails
+// credentials are read again from their sources (files, environment variables) as they could be rotated
+func openHost(db *dtype.Database) error {
+	err := connectDB(db)
+	if err == nil || !isAuthError(db, err) || !db.Secrets.IsSet() {
+		return err
+	}
+
+	changed, errResolve := parser.ResolveCredentials(db)
+	if errResolve != nil {
+		return fmt.Errorf("%v (%v)", err, errResolve)
+	}
+
+	if !changed {
+		return err
+	}
+
+	// try again with new credentials
+	return connectDB(db)
+}
+
+// isAuthError returns true if error is caused by failed authentication to database, the error
+// of driver is examined also when secrets have been masked in its message
+func isAuthError(db *dtype.Database, err error) bool {
+	d, ok := getDriver(db.Driver)
+	return ok && d.IsAuthError != nil && d.IsAuthError(redact.Cause(err))
+}
+
+// connectDB opens a database and verifies connection by calling ping to it
+func connectDB(db *dtype.Database) error {
+	// if port is not defined, set defaults
+	if isEmpty(db.Port) && usesTCPPort(db) {
+		db.Port = getDefaultPort(db)
+	}
 
-	default:
-		return fmt.Errorf("SQL Driver %s is not supported", db.Driver)
+	dsn, err := createDSN(db)
+	if err != nil {
+		return err
 	}
-	err := db.Executor.Open(db.Driver, dsn)
+
+	db.Executor.SetInitStatements(db.Init)
+	db.Executor.SetReadOnly(db.ReadOnly)
+	err = db.Executor.Open(db.Driver, dsn)
 	if err != nil {
 		return err
 	}
 
+	db.Executor.SetPool(db.Pool.MaxOpenConns, db.Pool.MaxIdleConns, db.Pool.ConnMaxLifetime)
+
 	// ping db to verify a connection
-	if err = db.Executor.Ping(); err != nil {
+	if err = pingDB(db); err != nil {
+		db.Executor.Close()
 		return err
 	}
 
 	if db.SelectDB != "" {
 		// switch the connection when SelectDB is defined in cfg
-		err = db.Executor.SwitchToDB(db.SelectDB)
-		if err != nil {
+		if err = switchToDB(db); err != nil {
+			db.Executor.Close()
 			return err
 		}
 	}
@@ -82,24 +187,97 @@ func openDB(db *dtype.Database) error {
 	return nil
 }
 
-// openDBs opens databases and verifies connections by calling ping to them
+// pingDB verifies a connection to database, the ping is cancelled when database timeout elapses
+func pingDB(db *dtype.Database) error {
+	ctx, cancel := withTimeout(db.Timeout)
+	defer cancel()
+
+	err := db.Executor.Ping(ctx)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("Ping timed out after %v, err=%v", db.Timeout, err)
+	}
+	return err
+}
+
+// switchToDB switches to the selected database, reconnecting (e.g. for postgres) is cancelled when
+// database timeout elapses
+func switchToDB(db *dtype.Database) error {
+	ctx, cancel := withTimeout(db.Timeout)
+	defer cancel()
+
+	err := db.Executor.SwitchToDB(ctx, db.SelectDB)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("Switching to database `%s` timed out after %v, err=%v", db.SelectDB, db.Timeout, err)
+	}
+	return err
+}
+
+// withTimeout returns context which is cancelled when timeout elapses, 0 means no timeout
+func withTimeout(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// OpenError aggregates errors of databases which cannot be opened
+type OpenError struct {
+	Failed map[string]error // errors of databases which cannot be opened, keys are databases names
+	Opened int              // number of databases opened successfully
+}
+
+// Error returns report of databases which cannot be opened
+func (e *OpenError) Error() string {
+	names := make([]string, 0, len(e.Failed))
+	for name := range e.Failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := make([]string, len(names))
+	for i, name := range names {
+		report[i] = fmt.Sprintf("%s: %v", name, redact.Error(e.Failed[name]))
+	}
+
+	return fmt.Sprintf("Cannot open %d of %d database(s): %s",
+		len(e.Failed), len(e.Failed)+e.Opened, strings.Join(report, "; "))
+}
+
+// openDBs opens databases and verifies connections by calling ping to them, each database is opened
+// independently and failures are aggregated into OpenError
 func openDBs(dbs map[string]*dtype.Database) error {
-	once := false
-	for i := range dbs {
-		err := openDB(dbs[i])
-		if err != nil {
-			return err
+	if len(dbs) == 0 {
+		return errors.New("Cannot open any of defined database")
+	}
+
+	openErr := &OpenError{Failed: map[string]error{}}
+	for dbName, db := range dbs {
+		if err := openDB(db); err != nil {
+			openErr.Failed[dbName] = err
+			continue
 		}
-		once = true
+		openErr.Opened++
 	}
 
-	if !once {
-		return errors.New("Cannot open any of defined database")
+	if len(openErr.Failed) > 0 {
+		return openErr
 	}
 
 	return nil
 }
 
+// initDBs opens databases, an error is returned only when none of them has been opened;
+// in other case the report of databases which cannot be opened is logged and collection
+// proceeds for the others (they will be reconnected later)
+func initDBs(dbs map[string]*dtype.Database) error {
+	err := openDBs(dbs)
+	if openErr, ok := err.(*OpenError); ok && openErr.Opened > 0 {
+		logf("%v", openErr)
+		return nil
+	}
+	return err
+}
+
 // closeDB closes a database
 func closeDB(db *dtype.Database) error {
 	if db.Active {
@@ -18,12 +18,12 @@ package dbi
 
 import (
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
 	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/parser"
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/redact"
 	"github.com/intelsdi-x/snap-plugin-utilities/config"
 	"github.com/intelsdi-x/snap/control/plugin"
 	"github.com/intelsdi-x/snap/control/plugin/cpolicy"
@@ -37,17 +37,30 @@ const (
 	Version = 4
 	// Type of plugin
 	Type = plugin.CollectorPluginType
+
+	// hostTag is tag of metric holding host of cluster which database is connected to
+	hostTag = "db_host"
 )
 
 // DbiPlugin holds information about the configuration database and defined queries
 type DbiPlugin struct {
 	databases   map[string]*dtype.Database
 	queries     map[string]*dtype.Query
+	backoffs    map[string]*backoff               // reconnection backoffs of inactive databases
+	refreshes   map[string]time.Time              // times of the next refresh of databases discovered on servers
+	watermarks  map[string]map[string]interface{} // watermarks of queries, keys are names of queries and databases
 	initialized bool
 }
 
 // CollectMetrics returns values of desired metrics defined in mts
 func (dbiPlg *DbiPlugin) CollectMetrics(mts []plugin.MetricType) ([]plugin.MetricType, error) {
+	metrics, err := dbiPlg.collectMetrics(mts)
+	// errors are passed to snap log, so secrets have to be masked
+	return metrics, redact.Error(err)
+}
+
+// collectMetrics returns values of desired metrics defined in mts
+func (dbiPlg *DbiPlugin) collectMetrics(mts []plugin.MetricType) ([]plugin.MetricType, error) {
 
 	var err error
 	metrics := []plugin.MetricType{}
@@ -61,12 +74,19 @@ func (dbiPlg *DbiPlugin) CollectMetrics(mts []plugin.MetricType) ([]plugin.Metri
 			// Cannot obtained sql settings
 			return nil, err
 		}
-		err = openDBs(dbiPlg.databases)
+		err = initDBs(dbiPlg.databases)
 		if err != nil {
 			return nil, err
 		}
 		dbiPlg.initialized = true
+	} else {
+		// try to reconnect to databases which became inactive
+		dbiPlg.reconnectDBs()
 	} // end of initialization
+
+	// discover databases on servers (if enabled)
+	dbiPlg.discoverDBs()
+
 	// execute dbs queries and get output
 	data, err = dbiPlg.executeQueries()
 	if err != nil {
@@ -79,7 +99,7 @@ func (dbiPlg *DbiPlugin) CollectMetrics(mts []plugin.MetricType) ([]plugin.Metri
 				Namespace_: m.Namespace(),
 				Data_:      value,
 				Timestamp_: time.Now(),
-				Tags_:      m.Tags(),
+				Tags_:      dbiPlg.tags(m),
 				Version_:   m.Version(),
 			}
 			metrics = append(metrics, metric)
@@ -98,6 +118,13 @@ func (dbiPlg *DbiPlugin) GetConfigPolicy() (*cpolicy.ConfigPolicy, error) {
 
 // GetMetricTypes returns metrics types exposed by snap-plugin-collector-dbi
 func (dbiPlg *DbiPlugin) GetMetricTypes(cfg plugin.ConfigType) ([]plugin.MetricType, error) {
+	mts, err := dbiPlg.getMetricTypes(cfg)
+	// errors are passed to snap log, so secrets have to be masked
+	return mts, redact.Error(err)
+}
+
+// getMetricTypes returns metrics types exposed by snap-plugin-collector-dbi
+func (dbiPlg *DbiPlugin) getMetricTypes(cfg plugin.ConfigType) ([]plugin.MetricType, error) {
 	metrics := map[string]interface{}{}
 	mts := []plugin.MetricType{}
 
@@ -121,7 +148,7 @@ func (dbiPlg *DbiPlugin) GetMetricTypes(cfg plugin.ConfigType) ([]plugin.MetricT
 
 // New returns snap-plugin-collector-dbi instance
 func New() *DbiPlugin {
-	dbiPlg := &DbiPlugin{databases: map[string]*dtype.Database{}, queries: map[string]*dtype.Query{}, initialized: false}
+	dbiPlg := &DbiPlugin{databases: map[string]*dtype.Database{}, queries: map[string]*dtype.Query{}, backoffs: map[string]*backoff{}, refreshes: map[string]time.Time{}, watermarks: map[string]map[string]interface{}{}, initialized: false}
 
 	return dbiPlg
 }
@@ -141,19 +168,52 @@ func (dbiPlg *DbiPlugin) setConfig(cfg interface{}) error {
 		return err
 	}
 
+	// reconnection backoffs, refreshes of discovery and watermarks refer to the databases and queries of previous configuration
+	dbiPlg.backoffs = map[string]*backoff{}
+	dbiPlg.refreshes = map[string]time.Time{}
+	dbiPlg.watermarks = map[string]map[string]interface{}{}
+
 	return nil
 }
 
+// tags returns tags of metric `m`, including host of cluster to which its database is connected
+func (dbiPlg *DbiPlugin) tags(m plugin.MetricType) map[string]string {
+	ns := m.Namespace().String() + "/"
+
+	connected, matched := "", ""
+	for dbName, db := range dbiPlg.databases {
+		// namespace starts with name of database (discovered databases extend name of the one which discovered them)
+		prefix := createNamespace(dbName, "", "", "") + "/"
+		if strings.HasPrefix(ns, prefix) && len(prefix) > len(matched) {
+			connected, matched = db.Connected, prefix
+		}
+	}
+
+	if connected == "" {
+		return m.Tags()
+	}
+
+	tags := map[string]string{}
+	for k, v := range m.Tags() {
+		tags[k] = v
+	}
+	tags[hostTag] = connected
+
+	return tags
+}
+
 // getMetrics returns map with dbi metrics values, where keys are metrics names
 func (dbiPlg *DbiPlugin) getMetrics() (map[string]interface{}, error) {
 	metrics := map[string]interface{}{}
 
-	err := openDBs(dbiPlg.databases)
+	err := initDBs(dbiPlg.databases)
 
 	if err != nil {
 		return nil, err
 	}
 
+	dbiPlg.discoverDBs()
+
 	// execute dbs queries and get statement outputs
 	metrics, err = dbiPlg.executeQueries()
 	if err != nil {
@@ -174,24 +234,56 @@ func (dbiPlg *DbiPlugin) getMetrics() (map[string]interface{}, error) {
 // executeQueries executes all defined queries of each database and returns results as map to its values,
 // where keys are equal to columns' names
 func (dbiPlg *DbiPlugin) executeQueries() (map[string]interface{}, error) {
-	data := map[string]interface{}{}
+	// metrics of connections are collected first, so a broken connection is detected before its queries are executed
+	data := dbiPlg.connectionMetrics()
+
+	// number of values obtained from queries, metrics of connections are always available
+	obtained := 0
 
 	//execute queries for each defined databases
 	for dbName, db := range dbiPlg.databases {
+		if db.Discover != nil {
+			// queries are executed against discovered databases
+			continue
+		}
+
 		if !db.Active {
 			//skip if db is not active (none established connection)
-			fmt.Fprintf(os.Stderr, "Cannot execute queries for database %s, is inactive (connection was not established properly)\n", dbName)
+			logf("Cannot execute queries for database %s, is inactive (connection was not established properly)", dbName)
 			continue
 		}
 
 		// retrive name from queries to be executed for this db
 		for _, queryName := range db.QrsToExec {
-			statement := dbiPlg.queries[queryName].Statement
+			query := dbiPlg.queries[queryName]
+
+			// query timeout overrides the database one
+			timeout := query.Timeout
+			if timeout == 0 {
+				timeout = db.Timeout
+			}
 
-			out, err := db.Executor.Query(queryName, statement)
+			// arguments given for the database override the ones of query
+			args, ok := db.QueryArgs[queryName]
+			if !ok {
+				args = query.Args
+			}
+
+			if query.Watermark != nil {
+				// watermark is bound as the last argument (args are copied, so the ones of query are not changed)
+				args = append(append([]interface{}{}, args...), dbiPlg.watermark(queryName, dbName, query))
+			}
+
+			ctx, cancel := withTimeout(timeout)
+			out, err := db.Executor.Query(ctx, queryName, query.Statement, query.Prepare, args...)
+			cancel()
 			if err != nil {
 				// log failing query and take the next one
-				fmt.Fprintf(os.Stderr, "Cannot execute query %s for database %s", queryName, dbName)
+				logf("Cannot execute query %s for database %s, err=%v", queryName, dbName, err)
+				if !checkConnection(dbName, db) {
+					// the connection is broken, skip the rest of queries for this database
+					break
+				}
 				continue
 			}
 
@@ -211,7 +303,7 @@ func (dbiPlg *DbiPlugin) executeQueries() (map[string]interface{}, error) {
 					instance := ""
 
 					if instanceOk {
-						instance = fmt.Sprintf("%v", fixDataType(out[instanceFrom][index]))
+						instance = fmt.Sprintf("%v", fixDataType(convertValue(db, out[instanceFrom][index])))
 					}
 
 					key := createNamespace(dbName, resName, res.InstancePrefix, instance)
@@ -220,30 +312,63 @@ func (dbiPlg *DbiPlugin) executeQueries() (map[string]interface{}, error) {
 						return nil, fmt.Errorf("Namespace `%s` has to be unique, but is not", key)
 					}
 
-					data[key] = fixDataType(value)
+					data[key] = fixDataType(convertValue(db, value))
+					obtained++
+				}
+			}
+
+			// watermark is advanced only when metrics are collected (not when metric types are listed),
+			// so rows queried for listing are not skipped by the first collection; it is advanced after
+			// the metrics are built, so the rows are queried again if building of metrics fails
+			if query.Watermark != nil && dbiPlg.initialized {
+				if err := dbiPlg.updateWatermark(queryName, dbName, query, db, out); err != nil {
+					logf("Cannot update watermark of query %s for database %s, err=%v", queryName, dbName, err)
 				}
 			}
 		} // end of range db_queries_to_execute
 	} // end of range databases
 
-	if len(data) == 0 {
+	if obtained == 0 {
 		return nil, fmt.Errorf("No data obtained from defined queries")
 	}
 
 	return data, nil
 }
 
-// fixDataType converts `arg` to a string if its type is an array of bytes or time.Time, in other case there is no change
+// fixDataType converts `arg` to a string if its type is an array of bytes or time.Time, integers and floats
+// of smaller sizes (returned e.g. by ClickHouse driver) are converted to int64, uint64 (values of UInt64 are
+// not converted to int64, so they cannot overflow) and float64; in other case there is no change
 func fixDataType(arg interface{}) interface{} {
 	var result interface{}
 
-	switch arg.(type) {
+	switch v := arg.(type) {
 	case []byte:
-		result = string(arg.([]byte))
+		result = string(v)
 
 	case time.Time:
 		// gob: type time.Time is not registered for gob interface, conversion to string
-		result = arg.(time.Time).String()
+		result = v.String()
+
+	case int8:
+		result = int64(v)
+	case int16:
+		result = int64(v)
+	case int32:
+		result = int64(v)
+	case int:
+		result = int64(v)
+
+	case uint8:
+		result = uint64(v)
+	case uint16:
+		result = uint64(v)
+	case uint32:
+		result = uint64(v)
+	case uint:
+		result = uint64(v)
+
+	case float32:
+		result = float64(v)
 
 	default:
 		result = arg
@@ -0,0 +1,237 @@
+// +build linux,medium,sqlite3
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbi
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/executor"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// sqliteSetfile is a setfile template for local SQLite database, `%s` is replaced with path to database file
+const sqliteSetfile = `{
+    "queries": [
+        {
+            "name": "stock",
+            "statement": "SELECT category, COUNT(*) AS value FROM product GROUP BY category",
+            "results": [
+                {
+                    "instance_from": "category",
+                    "value_from": "value"
+                }
+            ]
+        }
+    ],
+    "databases": [
+        {
+            "name": "warehouse",
+            "driver": "sqlite3",
+            "driver_option": {
+                "path": "%s"
+            },
+            "dbqueries": [
+                {
+                    "query": "stock"
+                }
+            ]
+        }
+    ]
+}`
+
+// createSQLiteDB creates SQLite database file in directory `dir` and fills it with exemplary data
+func createSQLiteDB(dir string) (string, error) {
+	path := filepath.Join(dir, "warehouse.db")
+
+	dsn, err := sqliteDSN(&dtype.Database{Path: path, Mode: "rwc"})
+	if err != nil {
+		return "", err
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	stmts := []string{
+		"CREATE TABLE product (name TEXT, category TEXT)",
+		"INSERT INTO product VALUES ('apple', 'fruit'), ('pear', 'fruit'), ('carrot', 'vegetable')",
+	}
+
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			return "", err
+		}
+	}
+
+	return path, nil
+}
+
+func TestSQLiteBackend(t *testing.T) {
+
+	Convey("collecting metrics from local SQLite database", t, func() {
+		dir, err := ioutil.TempDir("", "dbi")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		dbPath, err := createSQLiteDB(dir)
+		So(err, ShouldBeNil)
+
+		setfile := filepath.Join(dir, "setfile.json")
+		err = ioutil.WriteFile(setfile, []byte(fmt.Sprintf(sqliteSetfile, dbPath)), 0644)
+		So(err, ShouldBeNil)
+
+		Convey("metric types are exposed", func() {
+			cfg := plugin.NewPluginConfigType()
+			cfg.AddItem("setfile", ctypes.ConfigValueStr{Value: setfile})
+
+			mts, err := New().GetMetricTypes(cfg)
+			So(err, ShouldBeNil)
+			// results of query and metrics of connection
+			So(len(mts), ShouldEqual, 2+7)
+		})
+
+		Convey("metrics are collected", func() {
+			config := cdata.NewNode()
+			config.AddItem("setfile", ctypes.ConfigValueStr{Value: setfile})
+
+			mts := []plugin.MetricType{
+				plugin.MetricType{Namespace_: core.NewNamespace("intel", "dbi", "warehouse", "fruit"), Config_: config},
+				plugin.MetricType{Namespace_: core.NewNamespace("intel", "dbi", "warehouse", "vegetable"), Config_: config},
+				plugin.MetricType{Namespace_: core.NewNamespace("intel", "dbi", "warehouse", "_connection", "up"), Config_: config},
+			}
+
+			results, err := New().CollectMetrics(mts)
+			So(err, ShouldBeNil)
+			So(len(results), ShouldEqual, 3)
+			So(results[0].Data(), ShouldEqual, 2)
+			So(results[1].Data(), ShouldEqual, 1)
+			So(results[2].Data(), ShouldEqual, 1)
+		})
+	})
+}
+
+func TestReadOnlySession(t *testing.T) {
+
+	Convey("executing statement which modifies data in read-only session", t, func() {
+		dir, err := ioutil.TempDir("", "dbi")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		dbPath, err := createSQLiteDB(dir)
+		So(err, ShouldBeNil)
+
+		// the database file is opened for writing, so only the transaction prevents the modification
+		dsn, err := sqliteDSN(&dtype.Database{Path: dbPath, Mode: "rw"})
+		So(err, ShouldBeNil)
+
+		se := executor.NewExecutor()
+		se.SetReadOnly(true)
+		So(se.Open("sqlite3", dsn), ShouldBeNil)
+		defer se.Close()
+
+		se.Query(context.Background(), "cleanup", "DELETE FROM product RETURNING name", true)
+
+		out, err := se.Query(context.Background(), "count", "SELECT COUNT(*) AS value FROM product", true)
+		So(err, ShouldBeNil)
+		So(out["value"], ShouldResemble, []interface{}{int64(3)})
+	})
+}
+
+func TestSQLiteAttach(t *testing.T) {
+
+	Convey("switching to SQLite database by attaching it", t, func() {
+		dir, err := ioutil.TempDir("", "dbi")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		dbPath, err := createSQLiteDB(dir)
+		So(err, ShouldBeNil)
+
+		// the main database is empty, the tables of attached one are accessible without schema name
+		dsn, err := sqliteDSN(&dtype.Database{Path: filepath.Join(dir, "main.db"), Mode: "rwc"})
+		So(err, ShouldBeNil)
+
+		se := executor.NewExecutor()
+		se.SetReadOnly(true)
+		So(se.Open("sqlite3", dsn), ShouldBeNil)
+		defer se.Close()
+
+		So(se.SwitchToDB(context.Background(), dbPath), ShouldBeNil)
+
+		// no connection is kept idle, so each query is executed on a new connection
+		se.SetPool(2, 0, 0)
+
+		out, err := se.Query(context.Background(), "count", "SELECT COUNT(*) AS value FROM product", true)
+		So(err, ShouldBeNil)
+		So(out["value"], ShouldResemble, []interface{}{int64(3)})
+
+		out, err = se.Query(context.Background(), "qualified", "SELECT COUNT(*) AS value FROM warehouse.product", true)
+		So(err, ShouldBeNil)
+		So(out["value"], ShouldResemble, []interface{}{int64(3)})
+	})
+}
+
+func TestInitStatements(t *testing.T) {
+
+	Convey("initializing connections by init statements", t, func() {
+		dir, err := ioutil.TempDir("", "dbi")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		dbPath, err := createSQLiteDB(dir)
+		So(err, ShouldBeNil)
+
+		db := &dtype.Database{Driver: "sqlite3", Path: dbPath, Mode: "ro", ReadOnly: true,
+			Pool: dtype.Pool{MaxOpenConns: 2, MaxIdleConns: 0}, Executor: executor.NewExecutor()}
+
+		Convey("each connection of pool is initialized", func() {
+			db.Init = []string{"PRAGMA cache_size = 123"}
+			So(openDB(db), ShouldBeNil)
+			defer closeDB(db)
+
+			// idle connections are not retained, so each query opens a new connection
+			for i := 0; i < 2; i++ {
+				out, err := db.Executor.Query(context.Background(), "cache", "PRAGMA cache_size", true)
+				So(err, ShouldBeNil)
+				So(out["cache_size"], ShouldResemble, []interface{}{int64(123)})
+			}
+		})
+
+		Convey("database cannot be opened when init statement fails", func() {
+			db.Init = []string{"SELECT * FROM missing"}
+			err := openDB(db)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "Init statement `SELECT * FROM missing` failed")
+			So(db.Active, ShouldBeFalse)
+		})
+	})
+}
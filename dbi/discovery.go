@@ -0,0 +1,146 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbi
+
+import (
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/executor"
+)
+
+// discoverySeparator separates name of database with discovery and name of discovered database,
+// so the discovered one is a separate element of metrics namespace
+const discoverySeparator = "/"
+
+// discoverDBs refreshes the lists of databases discovered on servers whose refresh interval has elapsed,
+// new databases are opened and the ones which no longer exist are closed
+func (dbiPlg *DbiPlugin) discoverDBs() {
+	now := time.Now()
+
+	// databases map is changed by discovery, so it cannot be ranged over at the same time
+	parents := []string{}
+	for dbName, db := range dbiPlg.databases {
+		if db.Discover != nil && db.Active && !now.Before(dbiPlg.refreshes[dbName]) {
+			parents = append(parents, dbName)
+		}
+	}
+
+	for _, dbName := range parents {
+		db := dbiPlg.databases[dbName]
+
+		names, err := listDatabases(db)
+		if err != nil {
+			logf("Cannot discover databases of %s, err=%v", dbName, err)
+			if !checkConnection(dbName, db) {
+				// discovery is repeated as soon as the connection is reestablished
+				continue
+			}
+		} else {
+			dbiPlg.updateDiscovered(dbName, db, filterDatabases(db.Discover, names))
+		}
+
+		dbiPlg.refreshes[dbName] = now.Add(db.Discover.Interval)
+	}
+}
+
+// updateDiscovered adds databases with names `names` discovered by database `parentName` and removes
+// the ones which are no longer discovered
+func (dbiPlg *DbiPlugin) updateDiscovered(parentName string, parent *dtype.Database, names []string) {
+	discovered := map[string]bool{}
+
+	for _, name := range names {
+		dbName := parentName + discoverySeparator + strings.Replace(name, discoverySeparator, "_", -1)
+		discovered[dbName] = true
+
+		if _, exist := dbiPlg.databases[dbName]; exist {
+			continue
+		}
+
+		db := discoveredDB(dbName, parentName, parent, name)
+		dbiPlg.databases[dbName] = db
+
+		// when opening fails, it is retried by reconnection of inactive databases
+		if err := openDB(db); err != nil {
+			logf("Cannot open discovered database %s, err=%v", dbName, err)
+		}
+	}
+
+	for dbName, db := range dbiPlg.databases {
+		if db.Parent != parentName || discovered[dbName] {
+			continue
+		}
+
+		if err := closeDB(db); err != nil {
+			logf("Cannot close database %s which is no longer discovered, err=%v", dbName, err)
+		}
+		delete(dbiPlg.databases, dbName)
+		delete(dbiPlg.backoffs, dbName)
+	}
+}
+
+// discoveredDB returns database `dbName` discovered by database `parentName` on its server, it shares
+// connection settings and queries of parent and selects the discovered database `name`
+func discoveredDB(dbName, parentName string, parent *dtype.Database, name string) *dtype.Database {
+	db := *parent
+	db.Name = dbName
+	db.SelectDB = name
+	db.Discover = nil
+	db.Parent = parentName
+	db.Connected = ""
+	db.Active = false
+	db.Pool = discoveredPool(parent.Pool)
+	db.Executor = executor.NewExecutor()
+
+	return &db
+}
+
+// discoveredPool returns settings of connection pool of discovered database, each discovered database
+// has its own pool (the database is selected on its connections), so it is limited to a single connection,
+// otherwise the pool of parent would be multiplied by the number of databases; a single connection
+// is enough as queries of database are executed one after another
+func discoveredPool(parent dtype.Pool) dtype.Pool {
+	pool := dtype.Pool{MaxOpenConns: 1, MaxIdleConns: 1, ConnMaxLifetime: parent.ConnMaxLifetime}
+	if parent.MaxIdleConns <= 0 {
+		// idle connections are not retained by parent, so neither by discovered databases
+		pool.MaxIdleConns = 0
+	}
+	return pool
+}
+
+// listDatabases returns names of databases on the server of database `db`
+func listDatabases(db *dtype.Database) ([]string, error) {
+	ctx, cancel := withTimeout(db.Timeout)
+	defer cancel()
+	return db.Executor.ListDatabases(ctx)
+}
+
+// filterDatabases returns names of databases which match include pattern and do not match exclude pattern
+func filterDatabases(discover *dtype.Discovery, names []string) []string {
+	filtered := []string{}
+	for _, name := range names {
+		if discover.Include != nil && !discover.Include.MatchString(name) {
+			continue
+		}
+		if discover.Exclude != nil && discover.Exclude.MatchString(name) {
+			continue
+		}
+		filtered = append(filtered, name)
+	}
+	return filtered
+}
@@ -0,0 +1,152 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbi
+
+import (
+	"math/big"
+	"strconv"
+	"sync"
+
+	_ "github.com/ClickHouse/clickhouse-go"
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/executor"
+)
+
+// Driver describes sql driver which can be used in setfile, drivers which are not built in
+// can be added by RegisterDriver
+type Driver struct {
+	// DSN returns data source name of database created from its driver options (required)
+	DSN func(db *dtype.Database) (string, error)
+
+	// ExtendsDSN says if data source name given explicitly in driver options is passed to DSN (e.g. to be
+	// completed with credentials), otherwise it is passed to sql driver as it is
+	ExtendsDSN bool
+
+	// DefaultPort returns port used when it is not given in driver options, nil for drivers without TCP port
+	DefaultPort func(db *dtype.Database) string
+
+	// Dialect implements operations specific for the driver (e.g. switching database), nil means
+	// that the default dialect is used (switching database is not supported)
+	Dialect executor.Dialect
+
+	// Convert converts value returned by the driver to the type of metric data, nil means no conversion
+	Convert func(value interface{}) interface{}
+
+	// IsAuthError returns true if error is caused by failed authentication (credentials are read
+	// again from their sources then), nil means that errors are not recognized
+	IsAuthError func(err error) bool
+}
+
+var (
+	driversMutex sync.RWMutex
+
+	// drivers maps names of sql drivers to their descriptions
+	drivers = map[string]Driver{}
+)
+
+func init() {
+	RegisterDriver("mysql", Driver{DSN: mysqlDSN, DefaultPort: port("3306"), Dialect: executor.MySQLDialect(), IsAuthError: isMySQLAuthError})
+	RegisterDriver("postgres", Driver{DSN: postgresDSN, DefaultPort: port("5432"), Dialect: executor.PostgresDialect(), IsAuthError: isPostgresAuthError})
+	RegisterDriver("mssql", Driver{DSN: mssqlDSN, DefaultPort: port("1433"), Dialect: executor.MSSQLDialect(), IsAuthError: isMSSQLAuthError})
+	RegisterDriver("sqlserver", Driver{DSN: mssqlDSN, DefaultPort: port("1433"), Dialect: executor.MSSQLDialect(), IsAuthError: isMSSQLAuthError})
+	RegisterDriver("clickhouse", Driver{DSN: clickhouseDSN, DefaultPort: clickhousePort, Dialect: executor.ClickHouseDialect(), Convert: convertClickHouse})
+}
+
+// RegisterDriver makes sql driver available in setfiles under `name`, the driver itself has to be registered
+// in database/sql under the same name; the dialect of driver (if given) is registered in executor;
+// it panics if it is called twice for the same name or DSN is nil
+func RegisterDriver(name string, d Driver) {
+	driversMutex.Lock()
+	defer driversMutex.Unlock()
+
+	if d.DSN == nil {
+		panic("dbi: DSN of driver " + name + " is nil")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("dbi: RegisterDriver called twice for driver " + name)
+	}
+
+	if d.Dialect != nil {
+		executor.RegisterDialect(name, d.Dialect)
+	}
+	drivers[name] = d
+}
+
+// getDriver returns description of driver, false if it is not registered
+func getDriver(name string) (Driver, bool) {
+	driversMutex.RLock()
+	defer driversMutex.RUnlock()
+
+	d, ok := drivers[name]
+	return d, ok
+}
+
+// convertValue converts value returned by the driver of database using its converter, if defined
+func convertValue(db *dtype.Database, value interface{}) interface{} {
+	if d, ok := getDriver(db.Driver); ok && d.Convert != nil {
+		return d.Convert(value)
+	}
+	return value
+}
+
+// port returns function returning always the same default port
+func port(p string) func(db *dtype.Database) string {
+	return func(*dtype.Database) string {
+		return p
+	}
+}
+
+// clickhousePort returns the default port of ClickHouse native protocol, secure connections
+// (enabled by `secure` param) are accepted on the other port
+func clickhousePort(db *dtype.Database) string {
+	if secure, _ := strconv.ParseBool(db.Params["secure"]); secure {
+		return "9440"
+	}
+	return "9000"
+}
+
+// convertClickHouse converts exact values of ClickHouse decimals (read by its dialect) to float64,
+// the other values are converted in the same way as for all drivers
+func convertClickHouse(value interface{}) interface{} {
+	if v, ok := value.(*big.Rat); ok {
+		f, _ := v.Float64()
+		return f
+	}
+	return value
+}
+
+// isMySQLAuthError returns true for ER_ACCESS_DENIED_ERROR
+func isMySQLAuthError(err error) bool {
+	e, ok := err.(*mysql.MySQLError)
+	return ok && e.Number == 1045
+}
+
+// isPostgresAuthError returns true for invalid_authorization_specification and invalid_password
+func isPostgresAuthError(err error) bool {
+	e, ok := err.(*pq.Error)
+	return ok && (e.Code == "28000" || e.Code == "28P01")
+}
+
+// isMSSQLAuthError returns true if login failed
+func isMSSQLAuthError(err error) bool {
+	e, ok := err.(mssql.Error)
+	return ok && e.Number == 18456
+}
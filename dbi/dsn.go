@@ -0,0 +1,193 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbi
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+)
+
+// mysqlParamEscaper escapes chars which break parsing of MySQL DSN parameters, the other chars
+// are left as they are because not all parameters are unescaped by the driver (e.g. charset)
+var mysqlParamEscaper = strings.NewReplacer("%", "%25", "&", "%26", "/", "%2F", "+", "%2B")
+
+// createDSN returns data source name of database, a data source name given explicitly
+// in driver options is passed to the driver as it is (unless the driver extends it, e.g. ODBC)
+func createDSN(db *dtype.Database) (string, error) {
+	d, ok := getDriver(db.Driver)
+	if !ok {
+		return "", fmt.Errorf("SQL Driver %s is not supported", db.Driver)
+	}
+
+	if isNotEmpty(db.DSN) && !d.ExtendsDSN {
+		return db.DSN, nil
+	}
+
+	return d.DSN(db)
+}
+
+// postgresDSN returns URL of PostgreSQL database, SSL is disabled unless it is enabled by TLS options
+func postgresDSN(db *dtype.Database) (string, error) {
+	tlsParams, err := postgresTLSParams(db.TLS)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	for k, v := range tlsParams {
+		params.Set(k, v)
+	}
+	for k, v := range db.Params {
+		params.Set(k, v)
+	}
+
+	u := &url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(db.Username, db.Password),
+		Path:   "/" + db.DBName,
+	}
+
+	if isNotEmpty(db.Socket) {
+		// directory of Unix domain socket is given as a host, the port is a part of socket file name
+		params.Set("host", db.Socket)
+		if isNotEmpty(db.Port) {
+			params.Set("port", db.Port)
+		}
+	} else {
+		u.Host = net.JoinHostPort(db.Host, db.Port)
+	}
+	u.RawQuery = params.Encode()
+
+	return u.String(), nil
+}
+
+// mysqlDSN returns DSN of MySQL database in format `username:password@protocol(address)/dbname?param=value`
+func mysqlDSN(db *dtype.Database) (string, error) {
+	protocol, address := "tcp", net.JoinHostPort(db.Host, db.Port)
+	if isNotEmpty(db.Socket) {
+		protocol, address = "unix", db.Socket
+	}
+
+	// the driver looks for the last `@` and `/` in DSN, so credentials do not need to be escaped
+	dsn := fmt.Sprintf("%s:%s@%s(%s)/%s",
+		db.Username, db.Password, protocol, address, db.DBName)
+
+	tlsName, err := registerMySQLTLS(db)
+	if err != nil {
+		return "", err
+	}
+
+	allParams := map[string]string{}
+	if isNotEmpty(tlsName) {
+		allParams["tls"] = tlsName
+	}
+	for k, v := range db.Params {
+		allParams[k] = v
+	}
+
+	if len(allParams) == 0 {
+		return dsn, nil
+	}
+
+	// sort params to get always the same DSN for given options
+	keys := make([]string, 0, len(allParams))
+	for k := range allParams {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	params := make([]string, len(keys))
+	for i, k := range keys {
+		params[i] = k + "=" + mysqlParamEscaper.Replace(allParams[k])
+	}
+
+	return dsn + "?" + strings.Join(params, "&"), nil
+}
+
+// mssqlDSN returns URL of SQL Server database, the instance name is optional
+func mssqlDSN(db *dtype.Database) (string, error) {
+	if isNotEmpty(db.Socket) {
+		return "", errors.New("Unix domain socket connections are not supported by SQL Server driver")
+	}
+
+	params := url.Values{}
+	for k, v := range db.Params {
+		params.Set(k, v)
+	}
+
+	if isNotEmpty(db.DBName) {
+		params.Set("database", db.DBName)
+	}
+
+	u := &url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(db.Username, db.Password),
+		Host:     db.Host,
+		Path:     db.Instance,
+		RawQuery: params.Encode(),
+	}
+
+	if isNotEmpty(db.Port) {
+		u.Host = net.JoinHostPort(db.Host, db.Port)
+	}
+
+	return u.String(), nil
+}
+
+// clickhouseDSN returns URL of ClickHouse database (native protocol), the database is selected
+// by the URL, so selectdb takes precedence over dbname
+func clickhouseDSN(db *dtype.Database) (string, error) {
+	if isNotEmpty(db.Socket) {
+		return "", errors.New("Unix domain socket connections are not supported by ClickHouse driver")
+	}
+
+	params := url.Values{}
+	if db.ReadOnly {
+		// only queries reading data (and changing settings) are allowed, ClickHouse has no transactions
+		params.Set("readonly", "2")
+	}
+	for k, v := range db.Params {
+		params.Set(k, v)
+	}
+
+	if isNotEmpty(db.Username) {
+		params.Set("username", db.Username)
+	}
+	if db.Password != "" {
+		params.Set("password", db.Password)
+	}
+
+	if isNotEmpty(db.SelectDB) {
+		params.Set("database", db.SelectDB)
+	} else if isNotEmpty(db.DBName) {
+		params.Set("database", db.DBName)
+	}
+
+	u := &url.URL{
+		Scheme:   "tcp",
+		Host:     net.JoinHostPort(db.Host, db.Port),
+		RawQuery: params.Encode(),
+	}
+
+	return u.String(), nil
+}
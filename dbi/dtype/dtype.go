@@ -17,22 +17,93 @@ limitations under the License.
 package dtype
 
 import (
+	"regexp"
+	"time"
+
 	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/executor"
 )
 
+// policies of selecting one of hosts of cluster
+const (
+	HostPolicyAny           = "any"            // the first reachable host
+	HostPolicyPrimaryOnly   = "primary-only"   // the first reachable primary host
+	HostPolicyPreferReplica = "prefer-replica" // the first reachable replica, primary if no replica is reachable
+)
+
 // Database holds connection information (driver, host, username etc.),
 // names of queries to perform and instance of executor which stores handle to db
 type Database struct {
+	Name      string
 	Driver    string
 	Host      string
 	Port      string
 	Username  string
 	Password  string
 	DBName    string
+	Instance  string            // name of database server instance (mssql)
+	Socket    string            // path to Unix domain socket (used instead of host and port)
+	Hosts     []string          // hosts of cluster ("host" or "host:port"), one of them is selected by policy
+	Policy    string            // policy of selecting one of hosts: "any", "primary-only", "prefer-replica"
+	Connected string            // host of cluster to which the database is connected
+	Path      string            // path to database file (used by file-based drivers, like sqlite3)
+	Mode      string            // open mode of database file: "ro", "rw", "rwc" (sqlite3)
+	Immutable bool              // database file is immutable (sqlite3)
+	DSN       string            // data source name passed to the driver as it is (overrides the other options)
+	Params    map[string]string // additional parameters merged into data source name
+	TLS       TLS
+	Secrets   Secrets
 	SelectDB  string
+	Discover  *Discovery // discovery of databases on the server, nil if disabled
+	Parent    string     // name of database whose discovery created this one
+	Pool      Pool
+	Timeout   time.Duration // timeout of connecting and executing queries (0 means no timeout)
+	ReadOnly  bool          // queries are executed in read-only transactions
+	Init      []string      // statements executed on each new connection
 	Executor  executor.Execution
 	Active    bool
-	QrsToExec []string // names of queries to be executed for the database
+	QrsToExec []string                 // names of queries to be executed for the database
+	QueryArgs map[string][]interface{} // arguments of queries overriding the ones of query, keys are names of queries
+}
+
+// Secrets holds names of files and environment variables from which username, password
+// and host of database are read, so they do not need to be stored in plain text in setfile
+type Secrets struct {
+	UsernameFile string
+	UsernameEnv  string
+	PasswordFile string
+	PasswordEnv  string
+	HostFile     string
+	HostEnv      string
+}
+
+// IsSet returns true if any source of secrets is given
+func (s Secrets) IsSet() bool {
+	return s != Secrets{}
+}
+
+// TLS holds settings of secure connection to database, by default TLS is disabled
+type TLS struct {
+	Mode       string // "disable", "require", "verify-ca" or "verify-full"
+	CA         string // path to file with CA certificates bundle (PEM)
+	Cert       string // path to file with client certificate (PEM)
+	Key        string // path to file with client private key (PEM)
+	ServerName string // name of server used to verify its certificate (host by default)
+	SkipVerify bool   // do not verify server certificate
+}
+
+// Discovery holds settings of discovering databases on the server, queries of database
+// are executed against each of discovered databases
+type Discovery struct {
+	Include  *regexp.Regexp // names of discovered databases have to match it (nil means all)
+	Exclude  *regexp.Regexp // databases whose names match it are skipped (nil means none)
+	Interval time.Duration  // interval of refreshing the list of databases
+}
+
+// Pool holds settings of database connection pool
+type Pool struct {
+	MaxOpenConns    int           // maximum number of open connections (<= 0 means unlimited)
+	MaxIdleConns    int           // maximum number of idle connections (<= 0 means no idle connections are retained)
+	ConnMaxLifetime time.Duration // maximum amount of time a connection may be reused (0 means no limit)
 }
 
 // Query holds statement of the query and its results (there is one or more) which
@@ -40,6 +111,18 @@ type Database struct {
 type Query struct {
 	Statement string
 	Results   map[string]Result
+	Timeout   time.Duration // timeout of query execution, overrides the database timeout when not 0
+	Prepare   bool          // statement is prepared once and reused, in other case it is executed directly
+	Args      []interface{} // arguments bound to placeholders of statement
+	Watermark *Watermark    // watermark bound as the last argument, nil if not used
+}
+
+// Watermark holds settings of watermark of query, which is the maximum value of column returned
+// by the previous execution of query (e.g. the last id of rows), so only new rows are queried
+type Watermark struct {
+	Column    string      // column whose maximum value is the watermark
+	Initial   interface{} // value bound before the first execution
+	StateFile string      // file in which watermarks are persisted across restarts (optional)
 }
 
 // Result holds information specified the columns whose values will be used to
@@ -0,0 +1,97 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"database/sql"
+	"math/big"
+	"regexp"
+	"strconv"
+)
+
+// clickhouseDecimalType matches ClickHouse decimal types, e.g. Decimal(18, 5), Decimal64(5) or Nullable(Decimal32(2)),
+// the submatch is the scale of decimal
+var clickhouseDecimalType = regexp.MustCompile(`^(?:Nullable\()?Decimal(?:32|64|128)?\((?:\d+,\s*)?(\d+)\)`)
+
+// clickhouseDialect selects the database by data source name and converts decimals read by the driver
+type clickhouseDialect struct{}
+
+// ClickHouseDialect returns dialect of ClickHouse, the database is selected by data source name
+func ClickHouseDialect() Dialect {
+	return clickhouseDialect{}
+}
+
+// SwitchToDB does nothing, the database has been already selected by data source name, because there is
+// no session kept between queries in which USE could be executed
+func (clickhouseDialect) SwitchToDB(ctx context.Context, se *SQLExecutor, dbName string) error {
+	return nil
+}
+
+// ListQuery returns statement listing databases on the server
+func (clickhouseDialect) ListQuery() string {
+	return "SHOW DATABASES"
+}
+
+// BeginReadOnly does not start transaction, ClickHouse has no transactions and its session
+// is made read-only by data source name
+func (clickhouseDialect) BeginReadOnly(ctx context.Context, conn *sql.Conn) (*sql.Tx, error) {
+	return nil, nil
+}
+
+// Converter returns function converting values of decimal column to their exact values
+func (clickhouseDialect) Converter(column *sql.ColumnType) func(value interface{}) interface{} {
+	match := clickhouseDecimalType.FindStringSubmatch(column.DatabaseTypeName())
+	if match == nil {
+		return nil
+	}
+
+	// the scale has been matched as a number
+	scale, _ := strconv.Atoi(match[1])
+	return func(value interface{}) interface{} {
+		return clickhouseDecimal(value, scale)
+	}
+}
+
+// clickhouseDecimal returns exact value of ClickHouse decimal, which is read by the driver as an integer
+// scaled by 10^scale (int32, int64 or 16 bytes of little-endian two's complement for Decimal128)
+func clickhouseDecimal(val interface{}, scale int) interface{} {
+	unscaled := new(big.Int)
+
+	switch v := val.(type) {
+	case int32:
+		unscaled.SetInt64(int64(v))
+	case int64:
+		unscaled.SetInt64(v)
+	case []byte:
+		if len(v) != 16 {
+			return val
+		}
+		bigEndian := make([]byte, len(v))
+		for i, b := range v {
+			bigEndian[len(v)-1-i] = b
+		}
+		unscaled.SetBytes(bigEndian)
+		if bigEndian[0]&0x80 != 0 {
+			// negative value
+			unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), 128))
+		}
+	default:
+		return val
+	}
+
+	denom := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	return new(big.Rat).SetFrac(unscaled, denom)
+}
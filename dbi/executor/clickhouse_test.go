@@ -0,0 +1,59 @@
+// +build linux,small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"math/big"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClickhouseDecimal(t *testing.T) {
+
+	Convey("converting ClickHouse decimal", t, func() {
+
+		Convey("of Decimal32", func() {
+			So(clickhouseDecimal(int32(1234), 2), ShouldResemble, big.NewRat(1234, 100))
+		})
+
+		Convey("of Decimal64", func() {
+			So(clickhouseDecimal(int64(-5), 3), ShouldResemble, big.NewRat(-5, 1000))
+		})
+
+		Convey("of Decimal128", func() {
+			// -1 in little-endian two's complement
+			minusOne := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+			So(clickhouseDecimal(minusOne, 1), ShouldResemble, big.NewRat(-1, 10))
+
+			two := make([]byte, 16)
+			two[0] = 2
+			So(clickhouseDecimal(two, 0), ShouldResemble, big.NewRat(2, 1))
+		})
+
+		Convey("of NULL", func() {
+			So(clickhouseDecimal(nil, 2), ShouldBeNil)
+		})
+
+		Convey("matching scale of decimal type", func() {
+			for typeName, scale := range map[string]string{"Decimal(18, 5)": "5", "Decimal64(3)": "3", "Nullable(Decimal32(2))": "2"} {
+				So(clickhouseDecimalType.FindStringSubmatch(typeName)[1], ShouldEqual, scale)
+			}
+			So(clickhouseDecimalType.FindStringSubmatch("UInt64"), ShouldBeNil)
+		})
+	})
+}
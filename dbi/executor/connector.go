@@ -0,0 +1,108 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+)
+
+// initConnector opens connections by the sql driver and initializes their sessions by executing
+// init statements, so each connection of pool (also the one opened after reconnection) is initialized
+type initConnector struct {
+	connector driver.Connector
+	stmts     []string
+}
+
+// dsnConnector opens connections by the driver which does not implement driver.DriverContext
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+// Connect opens connection with data source name
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver returns the underlying driver
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// openWithInit opens database whose connections are initialized by statements `stmts`
+func openWithInit(driverName, dsn string, stmts []string) (*sql.DB, error) {
+	// the driver registered under the name is obtained from handle which never opens any connection
+	handle, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := handle.Driver()
+	handle.Close()
+
+	var connector driver.Connector = dsnConnector{driver: drv, dsn: dsn}
+	if dc, ok := drv.(driver.DriverContext); ok {
+		if connector, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+
+	return sql.OpenDB(initConnector{connector: connector, stmts: stmts}), nil
+}
+
+// Connect opens connection and executes init statements on it, the connection is closed
+// when any of them fails
+func (c initConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stmt := range c.stmts {
+		if err := execConn(ctx, conn, stmt); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("Init statement `%s` failed, err=%v", stmt, err)
+		}
+	}
+
+	return conn, nil
+}
+
+// Driver returns the underlying driver
+func (c initConnector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+// execConn executes statement on driver connection, directly if the driver supports it
+// or as prepared statement in other case
+func execConn(ctx context.Context, conn driver.Conn, stmt string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, stmt, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+
+	s, err := conn.Prepare(stmt)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	_, err = s.Exec(nil)
+	return err
+}
@@ -0,0 +1,317 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Dialect implements operations whose SQL syntax or way of execution differs between drivers,
+// dialects are added by RegisterDialect (for drivers registered in dbi it is done by dbi.RegisterDriver)
+type Dialect interface {
+	// SwitchToDB changes the database used by queries of executor, reconnecting (if needed) is cancelled
+	// when the context is done
+	SwitchToDB(ctx context.Context, se *SQLExecutor, dbName string) error
+
+	// BeginReadOnly starts read-only transaction on the connection, nil transaction means that the session
+	// is read-only without transaction (e.g. it is set by data source name)
+	BeginReadOnly(ctx context.Context, conn *sql.Conn) (*sql.Tx, error)
+}
+
+// ColumnConverter is implemented by dialect of driver which returns values that can be interpreted
+// only with the type of their column
+type ColumnConverter interface {
+	// Converter returns function converting values of column, nil if they are not converted
+	Converter(column *sql.ColumnType) func(value interface{}) interface{}
+}
+
+// ReadOnlySession is implemented by dialect of driver whose session is made read-only by a statement,
+// it is executed once on each new connection instead of before each transaction
+type ReadOnlySession interface {
+	// ReadOnlyStatement returns statement making the session read-only, empty if there is no such statement
+	ReadOnlyStatement() string
+}
+
+// ReplicaDetector is implemented by dialect of driver which can detect if the database server
+// is a replica of cluster
+type ReplicaDetector interface {
+	// ReplicaQuery returns statement which returns single boolean value (or 0/1), true for replica
+	ReplicaQuery() string
+}
+
+// DatabaseLister is implemented by dialect of driver which can list databases on the server
+type DatabaseLister interface {
+	// ListQuery returns statement which returns names of databases in the first column,
+	// empty if listing databases is not supported
+	ListQuery() string
+}
+
+var (
+	dialectsMutex sync.RWMutex
+
+	// dialects maps names of sql drivers to their dialects
+	dialects = map[string]Dialect{}
+)
+
+// MySQLDialect returns dialect of MySQL, the database is switched by USE statement
+func MySQLDialect() Dialect {
+	return mysqlDialect{useDialect{quote: quoteMySQLIdentifier, maxLen: 64, readOnly: "SET SESSION TRANSACTION READ ONLY", list: "SHOW DATABASES"}}
+}
+
+// MSSQLDialect returns dialect of SQL Server, the database is switched by USE statement
+func MSSQLDialect() Dialect {
+	return useDialect{quote: quoteMSSQLIdentifier, maxLen: 128, list: "SELECT name FROM sys.databases"}
+}
+
+// ODBCDialect returns dialect of databases accessed through ODBC, the database is switched by USE statement
+// with name quoted in the way of SQL standard
+func ODBCDialect() Dialect {
+	return useDialect{quote: quoteANSIIdentifier, maxLen: 128}
+}
+
+// PostgresDialect returns dialect of PostgreSQL, the database is switched by reconnecting
+func PostgresDialect() Dialect {
+	return postgresDialect{}
+}
+
+// SQLiteDialect returns dialect of SQLite, the database is switched by attaching its file
+func SQLiteDialect() Dialect {
+	return sqliteDialect{}
+}
+
+// RegisterDialect makes the dialect available for sql driver `driverName`,
+// it panics if it is called twice for the same driver
+func RegisterDialect(driverName string, d Dialect) {
+	dialectsMutex.Lock()
+	defer dialectsMutex.Unlock()
+
+	if d == nil {
+		panic("executor: dialect of driver " + driverName + " is nil")
+	}
+	if _, dup := dialects[driverName]; dup {
+		panic("executor: RegisterDialect called twice for driver " + driverName)
+	}
+	dialects[driverName] = d
+}
+
+// dialectOf returns dialect of sql driver, the default one is returned for driver without dialect
+func dialectOf(driverName string) Dialect {
+	dialectsMutex.RLock()
+	defer dialectsMutex.RUnlock()
+
+	if d, ok := dialects[driverName]; ok {
+		return d
+	}
+	return defaultDialect{driver: driverName}
+}
+
+// sqliteSchemaName matches names of SQLite schemas (aliases of attached databases) which can be used unquoted
+var sqliteSchemaName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// defaultDialect is used by drivers without dialect, it does not support switching database
+// and read-only transactions are only rolled back
+type defaultDialect struct {
+	driver string
+}
+
+// SwitchToDB returns an error, because the way of switching database is not known
+func (d defaultDialect) SwitchToDB(ctx context.Context, se *SQLExecutor, dbName string) error {
+	return fmt.Errorf("Switching database is not supported by driver `%s`", d.driver)
+}
+
+// BeginReadOnly starts transaction, which is never committed
+func (defaultDialect) BeginReadOnly(ctx context.Context, conn *sql.Conn) (*sql.Tx, error) {
+	return conn.BeginTx(ctx, nil)
+}
+
+// useDialect changes the database by USE statement with quoted name of database
+type useDialect struct {
+	quote  func(name string) string
+	maxLen int
+
+	// statement making the session read-only, executed on each new connection (optional)
+	readOnly string
+
+	// statement listing databases on the server (optional)
+	list string
+}
+
+// ListQuery returns statement listing databases on the server
+func (d useDialect) ListQuery() string {
+	return d.list
+}
+
+// SwitchToDB executes USE statement on each connection, as it changes the database context of the current
+// session only
+func (d useDialect) SwitchToDB(ctx context.Context, se *SQLExecutor, dbName string) error {
+	if err := validateIdentifier(dbName, d.maxLen); err != nil {
+		return err
+	}
+
+	return se.AddInitStatement(ctx, "USE "+d.quote(dbName))
+}
+
+// ReadOnlyStatement returns statement making the session read-only
+func (d useDialect) ReadOnlyStatement() string {
+	return d.readOnly
+}
+
+// BeginReadOnly starts transaction, if the driver does not support read-only transactions (mysql)
+// the access mode of session set on connection applies to it, in other case (mssql) the transaction
+// is only rolled back
+func (d useDialect) BeginReadOnly(ctx context.Context, conn *sql.Conn) (*sql.Tx, error) {
+	return conn.BeginTx(ctx, nil)
+}
+
+// mysqlDialect changes the database by USE statement, replicas are recognized by read-only mode of server
+type mysqlDialect struct {
+	useDialect
+}
+
+// ReplicaQuery returns statement checking if the server is read-only, what is the common setting of replicas
+func (mysqlDialect) ReplicaQuery() string {
+	return "SELECT @@global.read_only"
+}
+
+// postgresDialect changes the database by reconnecting, because PostgreSQL connection is bound to one database
+type postgresDialect struct{}
+
+// SwitchToDB reopens the database with data source name in which the name of database is replaced
+func (postgresDialect) SwitchToDB(ctx context.Context, se *SQLExecutor, dbName string) error {
+	if err := validateIdentifier(dbName, 63); err != nil {
+		return err
+	}
+
+	dsn, err := postgresDSNWithDB(se.DataSourceName(), dbName)
+	if err != nil {
+		return err
+	}
+
+	return se.Reopen(ctx, dsn)
+}
+
+// BeginReadOnly starts read-only transaction
+func (postgresDialect) BeginReadOnly(ctx context.Context, conn *sql.Conn) (*sql.Tx, error) {
+	return conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+}
+
+// ListQuery returns statement listing databases which accept connections, templates are skipped
+func (postgresDialect) ListQuery() string {
+	return "SELECT datname FROM pg_database WHERE datallowconn AND NOT datistemplate"
+}
+
+// ReplicaQuery returns statement checking if the server is in recovery mode (standby)
+func (postgresDialect) ReplicaQuery() string {
+	return "SELECT pg_is_in_recovery()"
+}
+
+// sqliteDialect attaches the database file, so its tables can be queried (also without schema name
+// if they are not shadowed by tables of the main database)
+type sqliteDialect struct{}
+
+// SwitchToDB attaches the database file `dbName` as the schema named by the base name of file,
+// e.g. "/var/lib/archive.db" is attached as "archive"
+func (sqliteDialect) SwitchToDB(ctx context.Context, se *SQLExecutor, dbName string) error {
+	if strings.TrimSpace(dbName) == "" {
+		return errors.New("path to database file is empty")
+	}
+
+	schema := strings.TrimSuffix(filepath.Base(dbName), filepath.Ext(dbName))
+	if !sqliteSchemaName.MatchString(schema) {
+		return fmt.Errorf("name of database file `%s` cannot be used as a schema name", dbName)
+	}
+
+	uri := "file:" + (&url.URL{Path: dbName}).EscapedPath()
+	if se.ReadOnly() {
+		uri += "?mode=ro"
+	}
+
+	// attached database is visible only on the connection on which it was attached
+	return se.AddInitStatement(ctx, "ATTACH DATABASE "+quoteSQLString(uri)+" AS "+quoteANSIIdentifier(schema))
+}
+
+// BeginReadOnly starts transaction which is never committed, the database file is additionally opened read-only
+func (sqliteDialect) BeginReadOnly(ctx context.Context, conn *sql.Conn) (*sql.Tx, error) {
+	return conn.BeginTx(ctx, nil)
+}
+
+// validateIdentifier returns an error if `name` cannot be a name of database, even if it is quoted
+func validateIdentifier(name string, maxLen int) error {
+	if name == "" {
+		return errors.New("name of database is empty")
+	}
+
+	if len(name) > maxLen {
+		return fmt.Errorf("name of database `%s` is longer than %d characters", name, maxLen)
+	}
+
+	if strings.TrimSpace(name) != name {
+		return fmt.Errorf("name of database `%s` has leading or trailing spaces", name)
+	}
+
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("name of database %q contains control characters", name)
+		}
+	}
+
+	return nil
+}
+
+// quoteMySQLIdentifier returns MySQL quoted identifier (enclosed in backticks)
+func quoteMySQLIdentifier(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+// quoteMSSQLIdentifier returns SQL Server delimited identifier (enclosed in brackets)
+func quoteMSSQLIdentifier(name string) string {
+	return "[" + strings.Replace(name, "]", "]]", -1) + "]"
+}
+
+// quoteANSIIdentifier returns SQL standard delimited identifier (enclosed in double quotes)
+func quoteANSIIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// quoteSQLString returns SQL string literal (enclosed in single quotes)
+func quoteSQLString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// postgresDSNWithDB returns PostgreSQL data source name (URL or key-value pairs) with the name of database replaced
+func postgresDSNWithDB(dsn, dbName string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", err
+		}
+		u.Path = "/" + dbName
+		u.RawPath = ""
+		return u.String(), nil
+	}
+
+	// the last occurrence of key takes precedence, the value is quoted and escaped
+	value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(dbName)
+	return strings.TrimSpace(dsn) + " dbname='" + value + "'", nil
+}
@@ -15,25 +15,57 @@ limitations under the License.
 package executor
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/redact"
 )
 
 // Execution is an interface for mocking purposes of sql functions like open(), ping(), exec(), close() etc.
 type Execution interface {
+	SetInitStatements(stmts []string)
 	Open(driverName, dataSourceName string) error
+	SetPool(maxOpenConns, maxIdleConns int, connMaxLifetime time.Duration)
+	SetReadOnly(readOnly bool)
 	Close() error
-	Ping() error
-	SwitchToDB(dbName string) error
-	Query(name, statement string) (map[string][]interface{}, error)
+	Ping(ctx context.Context) error
+	Stats() sql.DBStats
+	SwitchToDB(ctx context.Context, dbName string) error
+	IsReplica(ctx context.Context) (bool, error)
+	ListDatabases(ctx context.Context) ([]string, error)
+	Query(ctx context.Context, name, statement string, prepare bool, args ...interface{}) (map[string][]interface{}, error)
 }
 
 // SQLExecutor keeps handle to sql database and map of prepared queries' statements
 type SQLExecutor struct {
-	handle *sql.DB
-	stmts  map[string]*sql.Stmt
+	handle   *sql.DB
+	driver   string
+	dsn      string
+	pool     pool
+	readOnly bool
+	init     []string // statements initializing each connection
+	stmts    map[string]*sql.Stmt
+}
+
+// pool holds settings of connection pool, so they can be applied again when the database is reopened
+type pool struct {
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+}
+
+// apply sets the settings of connection pool of database handle
+func (p pool) apply(handle *sql.DB) {
+	handle.SetMaxOpenConns(p.maxOpenConns)
+	handle.SetMaxIdleConns(p.maxIdleConns)
+	handle.SetConnMaxLifetime(p.connMaxLifetime)
 }
 
 // NewExecutor returns a pointer to SQLExecutor with initialized map of stmt
@@ -42,40 +74,203 @@ var NewExecutor = func() Execution {
 	return &SQLExecutor{stmts: make(map[string]*sql.Stmt)}
 }
 
+// SetInitStatements sets statements executed on each new connection (e.g. setting of session variables),
+// it has to be called before the database is opened
+func (se *SQLExecutor) SetInitStatements(stmts []string) {
+	se.init = stmts
+}
+
 // Open opens a database specified by its database driver name and a driver-specific
 // data source name. To verify that the data source name is valid, call Ping()
 // The Open function should be called just once. It is rarely necessary to close a DB.
 func (se *SQLExecutor) Open(driverName, dataSourceName string) error {
 	var err error
-	se.handle, err = sql.Open(driverName, dataSourceName)
-	return err
+	se.driver = driverName
+	se.dsn = dataSourceName
+	se.handle, err = se.openHandle(dataSourceName)
+
+	// statements prepared on the previous handle (before reconnection) cannot be reused
+	se.closeStmts()
+
+	// errors of drivers can contain the data source name
+	return redact.Error(err)
+}
+
+// SetPool sets the maximum number of open and idle connections to the database and
+// the maximum amount of time a connection may be reused
+func (se *SQLExecutor) SetPool(maxOpenConns, maxIdleConns int, connMaxLifetime time.Duration) {
+	se.pool = pool{maxOpenConns: maxOpenConns, maxIdleConns: maxIdleConns, connMaxLifetime: connMaxLifetime}
+	se.pool.apply(se.handle)
+}
+
+// SetReadOnly sets if queries are executed in read-only transactions, it has to be called before
+// the database is opened
+func (se *SQLExecutor) SetReadOnly(readOnly bool) {
+	se.readOnly = readOnly
 }
 
 // Close closes the database, releasing any open resources. It is rare to Close a DB,
 // as the DB handle is meant to be long-lived and shared between many goroutines.
 func (se *SQLExecutor) Close() error {
+	se.closeStmts()
 	return se.handle.Close()
 }
 
-// Ping verifies a connection to the database is still alive, establishing a connection if necessary
-func (se *SQLExecutor) Ping() error {
-	return se.handle.Ping()
+// Ping verifies a connection to the database is still alive, establishing a connection if necessary,
+// it is cancelled when the context is done
+func (se *SQLExecutor) Ping(ctx context.Context) error {
+	return redact.Error(se.handle.PingContext(ctx))
 }
 
-// SwitchToDB changes the database context to the specified database
-func (se *SQLExecutor) SwitchToDB(dbName string) error {
-	_, err := se.handle.Exec("USE " + dbName)
-	return err
+// Stats returns statistics of connection pool, zero if the database has not been opened
+func (se *SQLExecutor) Stats() sql.DBStats {
+	if se.handle == nil {
+		return sql.DBStats{}
+	}
+	return se.handle.Stats()
+}
+
+// SwitchToDB changes the database context to the specified database in the way specific for the driver,
+// reconnecting to the database (if needed) is cancelled when the context is done
+func (se *SQLExecutor) SwitchToDB(ctx context.Context, dbName string) error {
+	return redact.Error(dialectOf(se.driver).SwitchToDB(ctx, se, dbName))
+}
+
+// IsReplica returns true if the database server is a replica (standby) of cluster
+func (se *SQLExecutor) IsReplica(ctx context.Context) (bool, error) {
+	rd, ok := dialectOf(se.driver).(ReplicaDetector)
+	if !ok {
+		return false, fmt.Errorf("Detection of replica is not supported by driver `%s`", se.driver)
+	}
+
+	var value interface{}
+	if err := se.handle.QueryRowContext(ctx, rd.ReplicaQuery()).Scan(&value); err != nil {
+		return false, redact.Error(err)
+	}
+
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	return strconv.ParseBool(fmt.Sprint(value))
 }
 
-// Query executes a query and returns its output in convenient format (as a map to its values where keys are the names of columns)
-func (se *SQLExecutor) Query(name, statement string) (map[string][]interface{}, error) {
-	rows, err := execQuery(se, name, statement)
+// ListDatabases returns names of databases on the database server
+func (se *SQLExecutor) ListDatabases(ctx context.Context) ([]string, error) {
+	dl, ok := dialectOf(se.driver).(DatabaseLister)
+	if !ok || dl.ListQuery() == "" {
+		return nil, fmt.Errorf("Listing databases is not supported by driver `%s`", se.driver)
+	}
+
+	rows, err := se.handle.QueryContext(ctx, dl.ListQuery())
+	if err != nil {
+		return nil, redact.Error(err)
+	}
 	defer rows.Close()
 
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, redact.Error(err)
+		}
+		names = append(names, name)
+	}
+
+	return names, redact.Error(rows.Err())
+}
+
+// DataSourceName returns data source name with which the database has been opened
+func (se *SQLExecutor) DataSourceName() string {
+	return se.dsn
+}
+
+// ReadOnly returns true if queries are executed in read-only transactions
+func (se *SQLExecutor) ReadOnly() bool {
+	return se.readOnly
+}
+
+// Exec executes a statement which does not return rows (e.g. USE) on the database
+func (se *SQLExecutor) Exec(statement string, args ...interface{}) error {
+	_, err := se.handle.Exec(statement, args...)
+	return err
+}
+
+// AddInitStatement reopens the database with statement `stmt` added to init statements, so the state
+// of session it sets (e.g. selected database) applies to each connection of pool, also to the one opened
+// in place of a broken connection
+func (se *SQLExecutor) AddInitStatement(ctx context.Context, stmt string) error {
+	init := se.init
+	se.init = append(append([]string{}, init...), stmt)
+
+	if err := se.Reopen(ctx, se.dsn); err != nil {
+		se.init = init
+		return err
+	}
+	return nil
+}
+
+// Reopen replaces the handle to database with the one opened with the data source name `dsn`,
+// settings of connection pool are preserved; the connection is verified by ping, which is cancelled
+// when the context is done
+func (se *SQLExecutor) Reopen(ctx context.Context, dsn string) error {
+	handle, err := se.openHandle(dsn)
+	if err != nil {
+		return err
+	}
+	se.pool.apply(handle)
+
+	if err := handle.PingContext(ctx); err != nil {
+		handle.Close()
+		return err
+	}
+
+	se.closeStmts()
+	se.handle.Close()
+	se.handle = handle
+	se.dsn = dsn
+
+	return nil
+}
+
+// openHandle opens a database with data source name `dsn`, its connections are initialized
+// by init statements if they are given, preceded by the statement making read-only session (mysql)
+func (se *SQLExecutor) openHandle(dsn string) (*sql.DB, error) {
+	init := se.init
+	if rs, ok := dialectOf(se.driver).(ReadOnlySession); ok && se.readOnly && rs.ReadOnlyStatement() != "" {
+		init = append([]string{rs.ReadOnlyStatement()}, init...)
+	}
+
+	if len(init) == 0 {
+		return sql.Open(se.driver, dsn)
+	}
+	return openWithInit(se.driver, dsn, init)
+}
+
+// Query executes a query and returns its output in convenient format (as a map to its values where keys are the names of columns),
+// preparing and execution of the query are cancelled when the context is done
+func (se *SQLExecutor) Query(ctx context.Context, name, statement string, prepare bool, args ...interface{}) (map[string][]interface{}, error) {
+	table, err := se.query(ctx, name, statement, prepare, args)
+	// errors can contain the statement with sensitive literals
+	return table, redact.Error(err)
+}
+
+// query executes a query and returns its output as a map to values of columns
+func (se *SQLExecutor) query(ctx context.Context, name, statement string, prepare bool, args []interface{}) (map[string][]interface{}, error) {
+	rows, finish, err := execQuery(ctx, se, name, statement, prepare, args)
+	if err != nil && prepare && needsReprepare(err) {
+		// the prepared statement is no longer valid (e.g. the structure of table has changed or the connection
+		// has been broken), so it is prepared again
+		se.closeStmt(name)
+		rows, finish, err = execQuery(ctx, se, name, statement, prepare, args)
+	}
 	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("Query `%s` timed out, err=%+v", name, err)
+		}
 		return nil, fmt.Errorf("Cannot execute query `%+v`, err=%+v", statement, err)
 	}
+	defer finish()
+	defer rows.Close()
 
 	// get query output (rows) and parse it to map
 	cols, err := rows.Columns()
@@ -88,6 +283,18 @@ func (se *SQLExecutor) Query(name, statement string) (map[string][]interface{},
 		return nil, errors.New("Invalid row does not contain columns")
 	}
 
+	// converters of values which can be interpreted only with the type of their column
+	converters := make([]func(interface{}) interface{}, len(cols))
+	if cc, ok := dialectOf(se.driver).(ColumnConverter); ok {
+		types, err := rows.ColumnTypes()
+		if err != nil {
+			return nil, err
+		}
+		for i, t := range types {
+			converters[i] = cc.Converter(t)
+		}
+	}
+
 	table := map[string][]interface{}{}
 	vals := make([]interface{}, len(cols))
 	valsPtrs := make([]interface{}, len(vals))
@@ -104,29 +311,140 @@ func (se *SQLExecutor) Query(name, statement string) (map[string][]interface{},
 		}
 
 		for i, val := range vals {
+			if converters[i] != nil {
+				val = converters[i](val)
+			}
 			columnName := strings.ToLower(cols[i])
 			table[columnName] = append(table[columnName], val)
 		}
 		cnt++
 	} // end of row.Next()
 
+	if err = rows.Err(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("Query `%s` timed out, err=%+v", name, err)
+		}
+		return nil, err
+	}
+
 	return table, nil
 }
 
-// execQuery creates a prepared statement and executes a query that returns rows (typically a SELECT statement)
-func execQuery(se *SQLExecutor, name, statement string) (*sql.Rows, error) {
-	var err error
+// execQuery executes a query that returns rows (typically a SELECT statement) as a prepared statement
+// or directly if `prepare` is false, `args` are bound to placeholders of statement; returned function
+// has to be called when the rows are closed to finish the transaction of read-only query
+func execQuery(ctx context.Context, se *SQLExecutor, name, statement string, prepare bool, args []interface{}) (*sql.Rows, func(), error) {
+	var stmt *sql.Stmt
+	if prepare {
+		var err error
+		if stmt, err = se.prepare(ctx, name, statement); err != nil {
+			return nil, nil, err
+		}
+	}
 
-	// if query statement is not prepared (do not occured in map), prepare it
-	if se.stmts[name] == nil {
-		// preparing query statement is needed to use the newer protocol for MySQL driver
-		// which provides information about type of result's value (can be obtained by using reflection)
-		se.stmts[name], err = se.handle.Prepare(statement)
+	if se.readOnly {
+		tx, release, err := beginReadOnly(ctx, se)
 		if err != nil {
-			se.stmts[name].Close()
-			return nil, err
+			return nil, nil, err
+		}
+
+		// no transaction is started when the session is read-only without it
+		if tx != nil {
+			finish := func() {
+				// the transaction is never committed, so nothing done by the query is persisted
+				tx.Rollback()
+				release()
+			}
+
+			// execute query within read-only transaction, output data is returned as rows
+			var rows *sql.Rows
+			if stmt != nil {
+				rows, err = tx.StmtContext(ctx, stmt).QueryContext(ctx, args...)
+			} else {
+				rows, err = tx.QueryContext(ctx, statement, args...)
+			}
+			if err != nil {
+				finish()
+				return nil, nil, err
+			}
+
+			return rows, finish, nil
 		}
 	}
+
 	// execute query, output data is returned as rows
-	return se.stmts[name].Query()
+	if stmt != nil {
+		rows, err := stmt.QueryContext(ctx, args...)
+		return rows, func() {}, err
+	}
+	rows, err := se.handle.QueryContext(ctx, statement, args...)
+	return rows, func() {}, err
+}
+
+// prepare returns prepared statement of query `name`, the statement is prepared once and reused
+// until it is closed
+func (se *SQLExecutor) prepare(ctx context.Context, name, statement string) (*sql.Stmt, error) {
+	if stmt, ok := se.stmts[name]; ok {
+		return stmt, nil
+	}
+
+	// preparing query statement is needed to use the newer protocol for MySQL driver
+	// which provides information about type of result's value (can be obtained by using reflection)
+	stmt, err := se.handle.PrepareContext(ctx, statement)
+	if err != nil {
+		return nil, err
+	}
+
+	se.stmts[name] = stmt
+	return stmt, nil
+}
+
+// closeStmt closes prepared statement of query `name`, so it is prepared again when the query is executed
+func (se *SQLExecutor) closeStmt(name string) {
+	if stmt, ok := se.stmts[name]; ok {
+		stmt.Close()
+		delete(se.stmts, name)
+	}
+}
+
+// closeStmts closes all prepared statements
+func (se *SQLExecutor) closeStmts() {
+	for name := range se.stmts {
+		se.closeStmt(name)
+	}
+	se.stmts = make(map[string]*sql.Stmt)
+}
+
+// needsReprepare returns true if error of prepared statement execution means that the statement
+// has to be prepared again: the connection is broken, the server invalidated the statement
+// (MySQL error 1615, PostgreSQL cached plan) or it does not exist on the connection (e.g. when
+// connections are pooled by PgBouncer)
+func needsReprepare(err error) bool {
+	if err == driver.ErrBadConn {
+		return true
+	}
+
+	return reprepareError.MatchString(err.Error())
+}
+
+// reprepareError matches errors reported when prepared statement is no longer valid: MySQL error 1615
+// (Prepared statement needs to be re-prepared), PostgreSQL cached plan and missing statement errors
+var reprepareError = regexp.MustCompile(`Error 1615|cached plan must not change result type|prepared statement "[^"]*" does not exist`)
+
+// beginReadOnly starts read-only transaction in the way supported by the driver, returned function
+// has to be called when the transaction is finished to release its connection
+func beginReadOnly(ctx context.Context, se *SQLExecutor) (*sql.Tx, func(), error) {
+	conn, err := se.handle.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	release := func() { conn.Close() }
+
+	tx, err := dialectOf(se.driver).BeginReadOnly(ctx, conn)
+	if err != nil || tx == nil {
+		release()
+		return nil, nil, err
+	}
+
+	return tx, release, nil
 }
@@ -0,0 +1,96 @@
+// +build linux,medium,sqlite3
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPreparedStatements(t *testing.T) {
+
+	Convey("executing queries on SQLite database", t, func() {
+		se := &SQLExecutor{stmts: make(map[string]*sql.Stmt)}
+		So(se.Open("sqlite3", "file::memory:"), ShouldBeNil)
+		se.SetPool(1, 1, 0)
+		So(se.Exec("CREATE TABLE product (name TEXT)"), ShouldBeNil)
+
+		Convey("prepared statement is reused and closed with executor", func() {
+			_, err := se.Query(context.Background(), "count", "SELECT COUNT(*) AS value FROM product", true)
+			So(err, ShouldBeNil)
+			So(se.stmts, ShouldContainKey, "count")
+
+			So(se.Close(), ShouldBeNil)
+			So(se.stmts, ShouldBeEmpty)
+		})
+
+		Convey("statement is not prepared when it is disabled", func() {
+			se.SetReadOnly(true)
+			out, err := se.Query(context.Background(), "count", "SELECT COUNT(*) AS value FROM product", false)
+			So(err, ShouldBeNil)
+			So(out["value"], ShouldResemble, []interface{}{int64(0)})
+			So(se.stmts, ShouldBeEmpty)
+			se.Close()
+		})
+
+		Convey("arguments are bound to placeholders", func() {
+			So(se.Exec("INSERT INTO product VALUES ('apple'), ('pear')"), ShouldBeNil)
+			se.SetReadOnly(true)
+
+			for _, prepare := range []bool{true, false} {
+				out, err := se.Query(context.Background(), "count", "SELECT COUNT(*) AS value FROM product WHERE name <> ?", prepare, "apple")
+				So(err, ShouldBeNil)
+				So(out["value"], ShouldResemble, []interface{}{int64(1)})
+			}
+			se.Close()
+		})
+
+		Convey("statement which cannot be prepared is reported as failed", func() {
+			_, err := se.Query(context.Background(), "missing", "SELECT * FROM missing", true)
+			So(err, ShouldNotBeNil)
+			So(se.stmts, ShouldNotContainKey, "missing")
+			se.Close()
+		})
+
+		Convey("reopening is cancelled when context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			So(se.AddInitStatement(ctx, "PRAGMA cache_size = 123"), ShouldNotBeNil)
+			So(se.init, ShouldBeEmpty)
+			se.Close()
+		})
+	})
+}
+
+func TestNeedsReprepare(t *testing.T) {
+
+	Convey("recognizing errors of invalidated prepared statements", t, func() {
+		So(needsReprepare(errors.New("Error 1615: Prepared statement needs to be re-prepared")), ShouldBeTrue)
+		So(needsReprepare(errors.New("pq: cached plan must not change result type")), ShouldBeTrue)
+		So(needsReprepare(errors.New(`pq: prepared statement "1" does not exist`)), ShouldBeTrue)
+		So(needsReprepare(driver.ErrBadConn), ShouldBeTrue)
+		So(needsReprepare(errors.New("pq: relation \"missing\" does not exist")), ShouldBeFalse)
+	})
+}
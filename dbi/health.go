@@ -0,0 +1,68 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbi
+
+import (
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+)
+
+// connectionResult is name of result holding metrics of connection to database, it starts with underscore
+// to be distinguished from results of queries
+const connectionResult = "_connection"
+
+// connectionMetrics returns metrics of connections to databases (statistics of connection pool, latency of ping
+// and state of connection), they are available for each database even if no query is defined for it
+func (dbiPlg *DbiPlugin) connectionMetrics() map[string]interface{} {
+	data := map[string]interface{}{}
+
+	for dbName, db := range dbiPlg.databases {
+		for name, value := range connectionStats(dbName, db) {
+			data[createNamespace(dbName, connectionResult, "", name)] = value
+		}
+	}
+
+	return data
+}
+
+// connectionStats returns statistics of connection to database, the database is pinged to measure
+// the latency, if the connection is broken it is closed (it will be reconnected on the next collection)
+func connectionStats(dbName string, db *dtype.Database) map[string]interface{} {
+	// statistics are obtained before ping, because the database is closed when it fails
+	stats := db.Executor.Stats()
+
+	up := int64(0)
+	latency := 0.0
+	if db.Active {
+		start := time.Now()
+		if checkConnection(dbName, db) {
+			up = 1
+			latency = time.Since(start).Seconds()
+		}
+	}
+
+	return map[string]interface{}{
+		"open":          int64(stats.OpenConnections),
+		"in_use":        int64(stats.InUse),
+		"idle":          int64(stats.Idle),
+		"wait_count":    stats.WaitCount,
+		"wait_duration": stats.WaitDuration.Seconds(),
+		"ping_latency":  latency,
+		"up":            up,
+	}
+}
@@ -0,0 +1,29 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbi
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/redact"
+)
+
+// logf writes formatted message to standard error (which is passed to snap log) with masked secrets
+func logf(format string, args ...interface{}) {
+	fmt.Fprintln(os.Stderr, redact.String(fmt.Sprintf(format, args...)))
+}
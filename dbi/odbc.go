@@ -0,0 +1,85 @@
+// +build linux,odbc
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbi
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	_ "github.com/alexbrainman/odbc"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/executor"
+)
+
+// odbcValueEscaper escapes closing brace in value of ODBC connection string attribute enclosed in braces
+var odbcValueEscaper = strings.NewReplacer("}", "}}")
+
+// init registers ODBC driver, it requires cgo and unixODBC so it is built in only with `odbc` build tag
+func init() {
+	RegisterDriver("odbc", Driver{DSN: odbcDSN, ExtendsDSN: true, Dialect: executor.ODBCDialect()})
+}
+
+// odbcDSN returns ODBC connection string, the data source is given either by its name defined
+// in odbc.ini or by connection string (e.g. "Driver=SQLite3;Database=/var/lib/app.db"); credentials,
+// name of database and params are appended as attributes, but the ones given in connection string
+// take precedence (ODBC uses the first occurrence of attribute)
+func odbcDSN(db *dtype.Database) (string, error) {
+	if isEmpty(db.DSN) {
+		return "", errors.New("ODBC database requires a data source name or connection string (dsn)")
+	}
+
+	attrs := []string{}
+	if strings.Contains(db.DSN, "=") {
+		attrs = append(attrs, strings.TrimRight(strings.TrimSpace(db.DSN), ";"))
+	} else {
+		attrs = append(attrs, "DSN="+odbcValue(db.DSN))
+	}
+
+	if isNotEmpty(db.Username) {
+		attrs = append(attrs, "UID="+odbcValue(db.Username))
+	}
+	if db.Password != "" {
+		attrs = append(attrs, "PWD="+odbcValue(db.Password))
+	}
+	if isNotEmpty(db.DBName) {
+		attrs = append(attrs, "Database="+odbcValue(db.DBName))
+	}
+
+	// sort params to get always the same connection string for given options
+	keys := make([]string, 0, len(db.Params))
+	for k := range db.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		attrs = append(attrs, k+"="+odbcValue(db.Params[k]))
+	}
+
+	return strings.Join(attrs, ";") + ";", nil
+}
+
+// odbcValue returns value of ODBC connection string attribute, the value is enclosed
+// in braces when it contains characters with special meaning
+func odbcValue(value string) string {
+	if strings.ContainsAny(value, ";{}") || strings.TrimSpace(value) != value {
+		return "{" + odbcValueEscaper.Replace(value) + "}"
+	}
+	return value
+}
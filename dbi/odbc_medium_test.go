@@ -0,0 +1,76 @@
+// +build linux,medium,odbc,sqlite3
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestODBCBackend(t *testing.T) {
+
+	Convey("collecting metrics from SQLite database through ODBC", t, func() {
+		// SQLite ODBC driver (e.g. package libsqliteodbc) has to be registered in unixODBC
+		if err := exec.Command("odbcinst", "-q", "-d", "-n", "SQLite3").Run(); err != nil {
+			SkipSo("SQLite3 ODBC driver is not installed")
+			return
+		}
+
+		dir, err := ioutil.TempDir("", "dbi")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		dbPath, err := createSQLiteDB(dir)
+		So(err, ShouldBeNil)
+
+		// the same setfile as for sqlite3 driver, but the database is given by ODBC connection string
+		odbcSetfile := strings.NewReplacer(
+			`"driver": "sqlite3"`, `"driver": "odbc"`,
+			`"path": "%s"`, `"dsn": "Driver=SQLite3;Database=%s"`,
+		).Replace(sqliteSetfile)
+
+		setfile := filepath.Join(dir, "setfile.json")
+		err = ioutil.WriteFile(setfile, []byte(fmt.Sprintf(odbcSetfile, dbPath)), 0644)
+		So(err, ShouldBeNil)
+
+		config := cdata.NewNode()
+		config.AddItem("setfile", ctypes.ConfigValueStr{Value: setfile})
+
+		mts := []plugin.MetricType{
+			plugin.MetricType{Namespace_: core.NewNamespace("intel", "dbi", "warehouse", "fruit"), Config_: config},
+			plugin.MetricType{Namespace_: core.NewNamespace("intel", "dbi", "warehouse", "vegetable"), Config_: config},
+		}
+
+		results, err := New().CollectMetrics(mts)
+		So(err, ShouldBeNil)
+		So(len(results), ShouldEqual, 2)
+		So(fmt.Sprint(results[0].Data()), ShouldEqual, "2")
+		So(fmt.Sprint(results[1].Data()), ShouldEqual, "1")
+	})
+}
@@ -0,0 +1,50 @@
+// +build linux,small,odbc
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbi
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestODBCDSN(t *testing.T) {
+
+	Convey("creating ODBC connection string", t, func() {
+
+		Convey("for data source name", func() {
+			db := &dtype.Database{Driver: "odbc", DSN: "legacy", Username: "tester", Password: "pass;word", Params: map[string]string{"Timeout": "5"}}
+			dsn, err := createDSN(db)
+			So(err, ShouldBeNil)
+			So(dsn, ShouldEqual, "DSN=legacy;UID=tester;PWD={pass;word};Timeout=5;")
+		})
+
+		Convey("for connection string", func() {
+			db := &dtype.Database{Driver: "odbc", DSN: "Driver=SQLite3;Database=/var/lib/app.db;"}
+			dsn, err := createDSN(db)
+			So(err, ShouldBeNil)
+			So(dsn, ShouldEqual, "Driver=SQLite3;Database=/var/lib/app.db;")
+		})
+
+		Convey("without data source", func() {
+			_, err := createDSN(&dtype.Database{Driver: "odbc"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -21,12 +21,35 @@ package cfg
 type SQLConfig struct {
 	Queries   []QueryType     `json:"queries"`
 	Databases []DatabasesType `json:"databases"`
+	Sensitive []string        `json:"sensitive_literals"`
+
+	// templates of databases expanded into a database for each of their targets
+	Templates []DatabaseTemplateType `json:"database_templates"`
 }
 
 type QueryType struct {
 	Name      string            `json:"name"`
 	Statement string            `json:"statement"`
 	Results   []QueryResultType `json:"results"`
+	Timeout   string            `json:"timeout"`
+
+	// values bound to placeholders of statement (e.g. ? or $1, depending on driver)
+	Params []interface{} `json:"params"`
+
+	// maximum value of column bound as the last param of the next execution
+	Watermark *WatermarkType `json:"watermark"`
+
+	// statement which modifies data or structure of database has to be explicitly allowed
+	AllowWrite bool `json:"allow_write"`
+
+	// statement is prepared once and reused, nil means that the default (true) is used
+	Prepare *bool `json:"prepare"`
+}
+
+type WatermarkType struct {
+	Column    string      `json:"column"`
+	Initial   interface{} `json:"initial"`
+	StateFile string      `json:"state_file"`
 }
 
 type QueryResultType struct {
@@ -42,16 +65,92 @@ type DatabasesType struct {
 	DriverOption   DriverOptionType `json:"driver_option"`
 	SelectDb       string           `json:"selectdb"`
 	QueryToExecute []DBQueryType    `json:"dbqueries"`
+
+	// settings of connection pool, nil means that the default is used
+	MaxOpenConns    *int   `json:"max_open_conns"`
+	MaxIdleConns    *int   `json:"max_idle_conns"`
+	ConnMaxLifetime string `json:"conn_max_lifetime"`
+
+	// timeout of connecting and executing queries, can be overridden by query timeout
+	Timeout string `json:"timeout"`
+
+	// queries are executed in read-only sessions, nil means that the default (true) is used
+	ReadOnly *bool `json:"read_only"`
+
+	// statements executed on each new connection, e.g. setting of session variables
+	InitStatements []string `json:"init_statements"`
+
+	// databases discovered on the server, dbqueries are executed against each of them
+	Discovery *DiscoveryType `json:"discovery"`
+}
+
+type DiscoveryType struct {
+	Include  string `json:"include"`
+	Exclude  string `json:"exclude"`
+	Interval string `json:"interval"`
+}
+
+type DatabaseTemplateType struct {
+	DatabasesType
+	Targets []TargetType `json:"targets"`
+}
+
+type TargetType struct {
+	Name string `json:"name"`
+	Host string `json:"host"`
+
+	// target is expanded for each number of range (e.g. "1-300") or each host read from file
+	Range     string `json:"range"`
+	HostsFile string `json:"hosts_file"`
 }
 
 type DBQueryType struct {
 	QueryName string `json:"query"`
+
+	// values bound to placeholders of statement, they override params of query when not nil
+	Params []interface{} `json:"params"`
 }
 
 type DriverOptionType struct {
 	Host     string `json:"host"`
 	Username string `json:"username"`
 	Password string `json:"password"`
+
+	// credentials read from files or environment variables (the file takes precedence),
+	// they override username, password and host given above
+	UsernameFile string `json:"username_file"`
+	UsernameEnv  string `json:"username_env"`
+	PasswordFile string `json:"password_file"`
+	PasswordEnv  string `json:"password_env"`
+	HostFile     string `json:"host_file"`
+	HostEnv      string `json:"host_env"`
+
 	DbName   string `json:"dbname"`
 	Port     string `json:"port"`
+	Instance string `json:"instance"`
+	Socket   string `json:"socket"`
+
+	// hosts of cluster used instead of host, one of them is selected according to policy
+	Hosts      []string `json:"hosts"`
+	HostPolicy string   `json:"host_policy"`
+
+	// full data source name or additional parameters merged into the generated one
+	Dsn    string            `json:"dsn"`
+	Params map[string]string `json:"params"`
+
+	// options of file-based databases (sqlite3)
+	Path      string `json:"path"`
+	Mode      string `json:"mode"`
+	Immutable bool   `json:"immutable"`
+
+	TLS TLSOptionType `json:"tls"`
+}
+
+type TLSOptionType struct {
+	Mode       string `json:"mode"`
+	CA         string `json:"ca"`
+	Cert       string `json:"cert"`
+	Key        string `json:"key"`
+	ServerName string `json:"server_name"`
+	SkipVerify bool   `json:"skip_verify"`
 }
@@ -0,0 +1,83 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/redact"
+)
+
+// ResolveCredentials reads username, password and host of database from files or environment variables
+// referenced in its sources of secrets and returns true if any of them has changed since the last read
+func ResolveCredentials(db *dtype.Database) (bool, error) {
+	changed := false
+
+	items := []struct {
+		name  string
+		file  string
+		env   string
+		value *string
+	}{
+		{"username", db.Secrets.UsernameFile, db.Secrets.UsernameEnv, &db.Username},
+		{"password", db.Secrets.PasswordFile, db.Secrets.PasswordEnv, &db.Password},
+		{"host", db.Secrets.HostFile, db.Secrets.HostEnv, &db.Host},
+	}
+
+	for _, item := range items {
+		value, ok, err := readSecret(item.file, item.env)
+		if err != nil {
+			return changed, fmt.Errorf("Cannot read %s of database `%s`, err=%v", item.name, db.Name, err)
+		}
+
+		if ok && value != *item.value {
+			*item.value = value
+			changed = true
+		}
+	}
+
+	// password read from its source (also the rotated one) is masked in errors and logs
+	redact.Register(db.Password)
+
+	return changed, nil
+}
+
+// readSecret returns the contents of file `fName` (trailing new line is trimmed) or the value
+// of environment variable `envName`; the file takes precedence, false is returned if neither is given
+func readSecret(fName, envName string) (string, bool, error) {
+	if len(strings.TrimSpace(fName)) > 0 {
+		data, err := ioutil.ReadFile(fName)
+		if err != nil {
+			return "", false, err
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+
+	if len(strings.TrimSpace(envName)) > 0 {
+		value, exist := os.LookupEnv(envName)
+		if !exist {
+			return "", false, fmt.Errorf("environment variable `%s` is not set", envName)
+		}
+		return value, true, nil
+	}
+
+	return "", false, nil
+}
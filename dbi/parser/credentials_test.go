@@ -0,0 +1,80 @@
+// +build linux,small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestResolveCredentials(t *testing.T) {
+
+	Convey("resolving credentials of database", t, func() {
+		pwdFile, err := ioutil.TempFile("", "dbi")
+		So(err, ShouldBeNil)
+		defer os.Remove(pwdFile.Name())
+
+		_, err = pwdFile.WriteString("secret\n")
+		So(err, ShouldBeNil)
+		pwdFile.Close()
+
+		os.Setenv("DBI_TEST_USERNAME", "tester")
+		defer os.Unsetenv("DBI_TEST_USERNAME")
+
+		db := &dtype.Database{
+			Name:     "db",
+			Username: "plain",
+			Password: "plain",
+			Secrets:  dtype.Secrets{PasswordFile: pwdFile.Name(), UsernameEnv: "DBI_TEST_USERNAME"},
+		}
+
+		Convey("from file and environment variable", func() {
+			changed, err := ResolveCredentials(db)
+			So(err, ShouldBeNil)
+			So(changed, ShouldBeTrue)
+			So(db.Username, ShouldEqual, "tester")
+			So(db.Password, ShouldEqual, "secret")
+
+			Convey("and again after the password has been rotated", func() {
+				err := ioutil.WriteFile(pwdFile.Name(), []byte("rotated"), 0600)
+				So(err, ShouldBeNil)
+
+				changed, err := ResolveCredentials(db)
+				So(err, ShouldBeNil)
+				So(changed, ShouldBeTrue)
+				So(db.Password, ShouldEqual, "rotated")
+			})
+
+			Convey("and again when nothing has changed", func() {
+				changed, err := ResolveCredentials(db)
+				So(err, ShouldBeNil)
+				So(changed, ShouldBeFalse)
+			})
+		})
+
+		Convey("when environment variable is not set", func() {
+			db.Secrets.HostEnv = "DBI_TEST_NOT_SET"
+			_, err := ResolveCredentials(db)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
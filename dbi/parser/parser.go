@@ -20,14 +20,36 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
+	"net"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
 	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/executor"
 	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/parser/cfg"
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/redact"
 )
 
+// defaults of connection pool, a single connection is enough to execute queries one by one
+const (
+	defaultMaxOpenConns = 1
+	defaultMaxIdleConns = 1
+)
+
+// defaultPrepare says if statements of queries are prepared by default, it can be disabled for connection
+// poolers which do not support prepared statements (e.g. PgBouncer in transaction pooling mode)
+const defaultPrepare = true
+
+// defaultDiscoveryInterval is the default interval of refreshing the list of discovered databases
+const defaultDiscoveryInterval = 5 * time.Minute
+
+// defaultReadOnly says if queries are executed in read-only sessions by default, the session is
+// enforced differently by each driver (some only roll the changes back), so it has to be enabled explicitly
+const defaultReadOnly = false
+
 // Parser holds maps to queries and databases
 type Parser struct {
 	qrs map[string]*dtype.Query
@@ -60,6 +82,9 @@ func GetDBItemsFromConfig(fName string) (map[string]*dtype.Database, map[string]
 		return nil, nil, fmt.Errorf("Invalid structure of file `%v` to be unmarshalled", fName)
 	}
 
+	// literals which have to be masked in errors and logs, e.g. parts of statements
+	redact.Register(sqlCnf.Sensitive...)
+
 	p := &Parser{
 		qrs: map[string]*dtype.Query{},
 		dbs: map[string]*dtype.Database{},
@@ -68,7 +93,7 @@ func GetDBItemsFromConfig(fName string) (map[string]*dtype.Database, map[string]
 	for _, query := range sqlCnf.Queries {
 		err := p.addQuery(query)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, redact.Error(err)
 		}
 
 	}
@@ -76,7 +101,14 @@ func GetDBItemsFromConfig(fName string) (map[string]*dtype.Database, map[string]
 	for _, db := range sqlCnf.Databases {
 		err := p.addDatabase(db)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, redact.Error(err)
+		}
+	}
+
+	for _, tt := range sqlCnf.Templates {
+		err := p.addTemplate(tt)
+		if err != nil {
+			return nil, nil, redact.Error(err)
 		}
 	}
 
@@ -94,26 +126,122 @@ func (p *Parser) addDatabase(dt cfg.DatabasesType) error {
 		return fmt.Errorf("Data name `%+s` is not unique", dt.Name)
 	}
 
+	maxOpenConns := defaultMaxOpenConns
+	if dt.MaxOpenConns != nil {
+		maxOpenConns = *dt.MaxOpenConns
+	}
+
+	maxIdleConns := defaultMaxIdleConns
+	if dt.MaxIdleConns != nil {
+		maxIdleConns = *dt.MaxIdleConns
+	}
+
+	connMaxLifetime, err := parseDuration(dt.ConnMaxLifetime)
+	if err != nil {
+		return fmt.Errorf("Database `%+s` has invalid connection max lifetime, err=%v", dt.Name, err)
+	}
+
+	timeout, err := parseDuration(dt.Timeout)
+	if err != nil {
+		return fmt.Errorf("Database `%+s` has invalid timeout, err=%v", dt.Name, err)
+	}
+
+	hosts, policy, err := parseHosts(dt.DriverOption)
+	if err != nil {
+		return fmt.Errorf("Database `%+s` has invalid hosts, err=%v", dt.Name, err)
+	}
+
+	discover, err := parseDiscovery(dt)
+	if err != nil {
+		return fmt.Errorf("Database `%+s` has invalid discovery, err=%v", dt.Name, err)
+	}
+
+	for _, stmt := range dt.InitStatements {
+		if err := validateStatement(stmt); err != nil {
+			return fmt.Errorf("Database `%+s` has init statement which can modify database, err=%v", dt.Name, err)
+		}
+	}
+
+	readOnly := defaultReadOnly
+	if dt.ReadOnly != nil {
+		readOnly = *dt.ReadOnly
+	}
+
 	//getting info about which queries are to be executed
 	execQrs := []string{}
+	queryArgs := map[string][]interface{}{}
 	for _, q := range dt.QueryToExecute {
 		execQrs = append(execQrs, q.QueryName)
+
+		if q.Params != nil {
+			args, err := parseParams(q.Params)
+			if err != nil {
+				return fmt.Errorf("Database `%+s` has invalid params of query `%+s`, err=%v", dt.Name, q.QueryName, err)
+			}
+			queryArgs[q.QueryName] = args
+		}
 	}
 
-	// adding database to databases map
-	p.dbs[dt.Name] = &dtype.Database{
+	db := &dtype.Database{
+		Name:      dt.Name,
 		Driver:    dt.Driver,
 		Host:      dt.DriverOption.Host,
 		Port:      dt.DriverOption.Port,
 		Username:  dt.DriverOption.Username,
 		Password:  dt.DriverOption.Password,
 		DBName:    dt.DriverOption.DbName,
-		SelectDB:  dt.SelectDb,
+		Instance:  dt.DriverOption.Instance,
+		Socket:    dt.DriverOption.Socket,
+		Hosts:     hosts,
+		Policy:    policy,
+		Path:      dt.DriverOption.Path,
+		Mode:      dt.DriverOption.Mode,
+		Immutable: dt.DriverOption.Immutable,
+		DSN:       dt.DriverOption.Dsn,
+		Params:    dt.DriverOption.Params,
+		TLS: dtype.TLS{
+			Mode:       dt.DriverOption.TLS.Mode,
+			CA:         dt.DriverOption.TLS.CA,
+			Cert:       dt.DriverOption.TLS.Cert,
+			Key:        dt.DriverOption.TLS.Key,
+			ServerName: dt.DriverOption.TLS.ServerName,
+			SkipVerify: dt.DriverOption.TLS.SkipVerify,
+		},
+		Secrets: dtype.Secrets{
+			UsernameFile: dt.DriverOption.UsernameFile,
+			UsernameEnv:  dt.DriverOption.UsernameEnv,
+			PasswordFile: dt.DriverOption.PasswordFile,
+			PasswordEnv:  dt.DriverOption.PasswordEnv,
+			HostFile:     dt.DriverOption.HostFile,
+			HostEnv:      dt.DriverOption.HostEnv,
+		},
+		SelectDB: dt.SelectDb,
+		Discover: discover,
+		Timeout:  timeout,
+		ReadOnly: readOnly,
+		Init:     dt.InitStatements,
+		Pool: dtype.Pool{
+			MaxOpenConns:    maxOpenConns,
+			MaxIdleConns:    maxIdleConns,
+			ConnMaxLifetime: connMaxLifetime,
+		},
 		Active:    false,
 		QrsToExec: execQrs,
+		QueryArgs: queryArgs,
 		Executor:  executor.NewExecutor(),
 	}
 
+	// password given in setfile is masked even if it is overridden by the one read from file or environment variable
+	redact.Register(db.Password)
+
+	// read credentials from files and environment variables
+	if _, err := ResolveCredentials(db); err != nil {
+		return err
+	}
+
+	// adding database to databases map
+	p.dbs[dt.Name] = db
+
 	return nil
 }
 
@@ -128,6 +256,32 @@ func (p *Parser) addQuery(qt cfg.QueryType) error {
 		return fmt.Errorf("Query name `%+s` is not unique", qt.Name)
 	}
 
+	timeout, err := parseDuration(qt.Timeout)
+	if err != nil {
+		return fmt.Errorf("Query `%+s` has invalid timeout, err=%v", qt.Name, err)
+	}
+
+	if !qt.AllowWrite {
+		if err := validateStatement(qt.Statement); err != nil {
+			return fmt.Errorf("Query `%+s` has statement which can modify database (set allow_write to execute it), err=%v", qt.Name, err)
+		}
+	}
+
+	args, err := parseParams(qt.Params)
+	if err != nil {
+		return fmt.Errorf("Query `%+s` has invalid params, err=%v", qt.Name, err)
+	}
+
+	watermark, err := p.parseWatermark(qt.Watermark)
+	if err != nil {
+		return fmt.Errorf("Query `%+s` has invalid watermark, err=%v", qt.Name, err)
+	}
+
+	prepare := defaultPrepare
+	if qt.Prepare != nil {
+		prepare = *qt.Prepare
+	}
+
 	results := map[string]dtype.Result{}
 
 	for _, r := range qt.Results {
@@ -149,10 +303,146 @@ func (p *Parser) addQuery(qt cfg.QueryType) error {
 	p.qrs[qt.Name] = &dtype.Query{
 		Statement: qt.Statement,
 		Results:   results,
+		Timeout:   timeout,
+		Prepare:   prepare,
+		Args:      args,
+		Watermark: watermark,
 	}
 	return nil
 }
 
+// parseHosts returns hosts of cluster, to which the port of database is added if they do not include it,
+// and the policy of selecting one of them ("any" by default)
+func parseHosts(opt cfg.DriverOptionType) ([]string, string, error) {
+	policy := opt.HostPolicy
+	switch policy {
+	case "":
+		policy = dtype.HostPolicyAny
+	case dtype.HostPolicyAny, dtype.HostPolicyPrimaryOnly, dtype.HostPolicyPreferReplica:
+	default:
+		return nil, "", fmt.Errorf("host policy `%s` is not supported", policy)
+	}
+
+	if len(opt.Hosts) == 0 {
+		return nil, policy, nil
+	}
+
+	if len(strings.TrimSpace(opt.HostFile)) > 0 || len(strings.TrimSpace(opt.HostEnv)) > 0 {
+		return nil, "", fmt.Errorf("hosts cannot be combined with host read from file or environment variable")
+	}
+
+	hosts := make([]string, len(opt.Hosts))
+	for i, host := range opt.Hosts {
+		if len(strings.TrimSpace(host)) == 0 {
+			return nil, "", fmt.Errorf("host is empty")
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil && len(strings.TrimSpace(opt.Port)) > 0 {
+			// host does not include port
+			host = net.JoinHostPort(host, opt.Port)
+		}
+		hosts[i] = host
+	}
+
+	return hosts, policy, nil
+}
+
+// parseDiscovery returns settings of discovering databases on the server, nil if discovery is not enabled
+func parseDiscovery(dt cfg.DatabasesType) (*dtype.Discovery, error) {
+	if dt.Discovery == nil {
+		return nil, nil
+	}
+
+	if len(strings.TrimSpace(dt.SelectDb)) > 0 {
+		return nil, fmt.Errorf("selectdb cannot be combined with discovery")
+	}
+
+	discover := &dtype.Discovery{Interval: defaultDiscoveryInterval}
+
+	var err error
+	if len(dt.Discovery.Include) > 0 {
+		if discover.Include, err = regexp.Compile(dt.Discovery.Include); err != nil {
+			return nil, fmt.Errorf("invalid include pattern, err=%v", err)
+		}
+	}
+
+	if len(dt.Discovery.Exclude) > 0 {
+		if discover.Exclude, err = regexp.Compile(dt.Discovery.Exclude); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern, err=%v", err)
+		}
+	}
+
+	if len(strings.TrimSpace(dt.Discovery.Interval)) > 0 {
+		if discover.Interval, err = time.ParseDuration(dt.Discovery.Interval); err != nil {
+			return nil, fmt.Errorf("invalid interval, err=%v", err)
+		}
+		if discover.Interval <= 0 {
+			return nil, fmt.Errorf("interval has to be positive")
+		}
+	}
+
+	return discover, nil
+}
+
+// parseParams returns arguments bound to placeholders of statement, integral numbers are converted
+// to int64 (JSON numbers are unmarshalled as floats, which are not accepted e.g. by LIMIT clause)
+func parseParams(params []interface{}) ([]interface{}, error) {
+	args := make([]interface{}, len(params))
+	for i, param := range params {
+		switch v := param.(type) {
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<63 {
+				args[i] = int64(v)
+			} else {
+				args[i] = v
+			}
+		case string, bool, nil:
+			args[i] = v
+		default:
+			return nil, fmt.Errorf("param %d has unsupported type %T, only numbers, strings, booleans and null are allowed", i+1, param)
+		}
+	}
+	return args, nil
+}
+
+// parseWatermark returns settings of watermark of query, nil if it is not used
+func (p *Parser) parseWatermark(wt *cfg.WatermarkType) (*dtype.Watermark, error) {
+	if wt == nil {
+		return nil, nil
+	}
+
+	if len(strings.TrimSpace(wt.Column)) == 0 {
+		return nil, fmt.Errorf("column is empty")
+	}
+
+	if wt.Initial == nil {
+		return nil, fmt.Errorf("initial value is not given")
+	}
+
+	initial, err := parseParams([]interface{}{wt.Initial})
+	if err != nil {
+		return nil, fmt.Errorf("invalid initial value, err=%v", err)
+	}
+
+	if len(strings.TrimSpace(wt.StateFile)) > 0 {
+		// state file is rewritten by each query, so it cannot be shared
+		for name, q := range p.qrs {
+			if q.Watermark != nil && q.Watermark.StateFile == wt.StateFile {
+				return nil, fmt.Errorf("state file `%s` is already used by query `%s`", wt.StateFile, name)
+			}
+		}
+	}
+
+	return &dtype.Watermark{Column: wt.Column, Initial: initial[0], StateFile: wt.StateFile}, nil
+}
+
+// parseDuration parses duration string (like "300ms" or "1m30s"), empty string means zero duration
+func parseDuration(value string) (time.Duration, error) {
+	if len(strings.TrimSpace(value)) == 0 {
+		return 0, nil
+	}
+	return time.ParseDuration(value)
+}
+
 // expandFileName replaces name of environment variable with its value and returns expanded filename
 func expandFileName(fName string) string {
 
@@ -0,0 +1,214 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// writeKeywords are keywords of statements which modify data or structure of database (DML, DDL, DCL),
+// INTO is rejected also outside of INSERT as SELECT ... INTO creates a table or writes a file, stored
+// procedures and anonymous code blocks are rejected as they can modify anything, COPY and LOAD read
+// or write files, LOCK and HANDLER lock or access tables outside of transaction
+var writeKeywords = map[string]bool{
+	"INSERT":   true,
+	"INTO":     true,
+	"UPDATE":   true,
+	"DELETE":   true,
+	"MERGE":    true,
+	"UPSERT":   true,
+	"REPLACE":  true,
+	"TRUNCATE": true,
+	"CREATE":   true,
+	"ALTER":    true,
+	"DROP":     true,
+	"RENAME":   true,
+	"GRANT":    true,
+	"REVOKE":   true,
+	"EXEC":     true,
+	"EXECUTE":  true,
+	"CALL":     true,
+	"DO":       true,
+	"COPY":     true,
+	"LOCK":     true,
+	"HANDLER":  true,
+	"LOAD":     true,
+}
+
+// functionNames are write keywords which are also names of functions, e.g. REPLACE(str, from, to),
+// they are allowed when followed by parenthesis
+var functionNames = map[string]bool{
+	"INSERT":   true,
+	"REPLACE":  true,
+	"TRUNCATE": true,
+}
+
+// backslashEscapes are sets of quote characters in which backslash escapes the following character,
+// the rules differ between databases (none in standard SQL, in strings for mysql, in strings and quoted
+// identifiers for clickhouse), so statement is validated with each of them
+var backslashEscapes = []string{"", "'\"", "'\"`"}
+
+// token is a word or a punctuation character of SQL statement
+type token struct {
+	text string
+	word bool
+}
+
+// validateStatement returns an error if the statement modifies data or structure of database
+// or it consists of more than one statement
+func validateStatement(statement string) error {
+	for _, escapes := range backslashEscapes {
+		if err := validateTokens(tokenize(statement, escapes)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTokens returns an error if the tokens of statement contain a write keyword or a separator of statements
+func validateTokens(tokens []token) error {
+	for i, t := range tokens {
+		if t.text == ";" {
+			if i < len(tokens)-1 {
+				return fmt.Errorf("multiple statements separated by `;` are not allowed")
+			}
+			continue
+		}
+
+		keyword := strings.ToUpper(t.text)
+		if !t.word || !writeKeywords[keyword] {
+			continue
+		}
+
+		if functionNames[keyword] && i < len(tokens)-1 && tokens[i+1].text == "(" {
+			continue
+		}
+
+		// SHOW CREATE TABLE (mysql) returns the statement creating the table
+		if keyword == "CREATE" && i > 0 && strings.ToUpper(tokens[i-1].text) == "SHOW" {
+			continue
+		}
+
+		return fmt.Errorf("keyword `%s` is not allowed", keyword)
+	}
+
+	return nil
+}
+
+// tokenize splits SQL statement into words and punctuation characters, string literals,
+// quoted identifiers and comments are skipped; syntax which differs between databases is tokenized
+// in the stricter way, so nothing which could be executed is skipped; backslash escapes the following
+// character inside of quotes given in `escapes`
+func tokenize(statement string, escapes string) []token {
+	tokens := []token{}
+	s := []rune(statement)
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case unicode.IsSpace(c):
+			continue
+
+		case c == '-' && next(s, i) == '-' && (i+2 == len(s) || unicode.IsSpace(s[i+2])):
+			// single line comment (mysql requires whitespace after dashes)
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+
+		case c == '/' && next(s, i) == '*' && (i+2 == len(s) || s[i+2] != '!'):
+			// block comment, executable comments of mysql (/*! ... */) are tokenized
+			i += 2
+			for i < len(s) && !(s[i] == '*' && next(s, i) == '/') {
+				i++
+			}
+			i++
+
+		case c == '\'' || c == '"' || c == '`':
+			// string literal or quoted identifier, the quote character is escaped by doubling it
+			escaped := strings.ContainsRune(escapes, c)
+			for i++; i < len(s); i++ {
+				if escaped && s[i] == '\\' {
+					i++
+					continue
+				}
+				if s[i] == c {
+					if next(s, i) != c {
+						break
+					}
+					i++
+				}
+			}
+
+		case c == '$' && len(dollarTag(s, i)) > 0:
+			// dollar-quoted string (postgres), e.g. $$text$$ or $tag$text$tag$
+			tag := dollarTag(s, i)
+			i += len(tag)
+			for i < len(s) && !hasRunes(s, i, tag) {
+				i++
+			}
+			i += len(tag) - 1
+
+		case isWordChar(c):
+			start := i
+			for i < len(s) && isWordChar(s[i]) {
+				i++
+			}
+			tokens = append(tokens, token{text: string(s[start:i]), word: true})
+			i--
+
+		default:
+			tokens = append(tokens, token{text: string(c)})
+		}
+	}
+
+	return tokens
+}
+
+// next returns the character following the one at position `i`, or 0 at the end of statement
+func next(s []rune, i int) rune {
+	if i+1 < len(s) {
+		return s[i+1]
+	}
+	return 0
+}
+
+// dollarTag returns the tag of postgres dollar-quoted string starting at position `i`
+// (including both dollar signs), nil is returned if there is no such tag (e.g. for placeholder $1)
+func dollarTag(s []rune, i int) []rune {
+	for j := i + 1; j < len(s); j++ {
+		if s[j] == '$' {
+			return s[i : j+1]
+		}
+		if !isWordChar(s[j]) || (j == i+1 && unicode.IsDigit(s[j])) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// hasRunes returns true if the sequence `r` occurs in `s` at position `i`
+func hasRunes(s []rune, i int, r []rune) bool {
+	return i+len(r) <= len(s) && string(s[i:i+len(r)]) == string(r)
+}
+
+// isWordChar returns true if the character can be a part of keyword or identifier
+func isWordChar(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
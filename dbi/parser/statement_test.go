@@ -0,0 +1,92 @@
+// +build linux,small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/parser/cfg"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidateStatement(t *testing.T) {
+
+	Convey("validating statement", t, func() {
+
+		Convey("accepts read-only statements", func() {
+			statements := []string{
+				"SELECT category, COUNT(*) AS value FROM product GROUP BY category;",
+				"SELECT 'DROP TABLE product; DELETE' AS text, \"update\", `insert` FROM t",
+				"SELECT REPLACE(name, 'a', 'b') FROM t -- DELETE FROM t; \n",
+				"SELECT 1 /* ; DROP TABLE t */",
+				"SELECT $$ DELETE FROM t; $$, $tag$ it's $$ DROP $tag$ FROM t WHERE id = $1",
+				"WITH updated AS (SELECT 1) SELECT * FROM updated",
+				"SHOW CREATE TABLE product",
+				"SELECT 'C:\\' AS path, INSERT(name, 1, 2, 'x'), TRUNCATE(price, 2) FROM t",
+			}
+			for _, statement := range statements {
+				So(validateStatement(statement), ShouldBeNil)
+			}
+		})
+
+		Convey("rejects statements modifying database", func() {
+			statements := []string{
+				"INSERT INTO t VALUES (1)",
+				"update t SET a = 1",
+				"WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d",
+				"DROP TABLE t",
+				"ALTER TABLE t ADD c INT",
+				"GRANT ALL ON t TO public",
+				"SELECT 1 /*!50000 DROP TABLE t */",
+				"SELECT 1--1\nDELETE FROM t",
+				"SELECT * INTO new_table FROM t",
+				"SELECT * FROM t INTO OUTFILE '/tmp/t.csv'",
+				"EXEC sp_cleanup",
+				"EXECUTE cleanup_plan(1)",
+				"CALL cleanup()",
+				"SELECT 'a\\'' INTO OUTFILE '/tmp/x' -- '",
+				"SELECT \"a\\\"\" INTO OUTFILE '/tmp/x' -- \"",
+				"SELECT `a\\`` INTO OUTFILE '/tmp/x' -- `",
+				"EXEC('DELETE FROM t')",
+				"EXECUTE('DROP TABLE t')",
+				"CALL(cleanup)",
+				"DO $$ BEGIN DELETE FROM t; END $$",
+				"COPY t FROM '/tmp/t.csv'",
+				"COPY (SELECT * FROM t) TO '/tmp/t.csv'",
+				"LOCK TABLES t WRITE",
+				"HANDLER t OPEN",
+				"LOAD DATA INFILE '/tmp/t.csv' INTO TABLE t",
+			}
+			for _, statement := range statements {
+				So(validateStatement(statement), ShouldNotBeNil)
+			}
+		})
+
+		Convey("rejects multiple statements", func() {
+			So(validateStatement("SELECT 1; SELECT 2"), ShouldNotBeNil)
+			So(validateStatement("SELECT 'a'';' ; SELECT 2"), ShouldNotBeNil)
+		})
+
+		Convey("when query explicitly allows writing", func() {
+			p := &Parser{qrs: map[string]*dtype.Query{}}
+			So(p.addQuery(cfg.QueryType{Name: "q1", Statement: "DELETE FROM t"}), ShouldNotBeNil)
+			So(p.addQuery(cfg.QueryType{Name: "q2", Statement: "DELETE FROM t", AllowWrite: true}), ShouldBeNil)
+		})
+	})
+}
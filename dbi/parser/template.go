@@ -0,0 +1,215 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/parser/cfg"
+)
+
+// placeholders replaced in name and host of target
+const (
+	numberPlaceholder = "{n}"
+	hostPlaceholder   = "{host}"
+)
+
+// target is a database expanded from target of template
+type target struct {
+	name string
+	host string
+	port string
+}
+
+// maxRangeSize limits the number of databases expanded from a range, so a typo cannot exhaust resources
+const maxRangeSize = 10000
+
+// addTemplate adds database instance for each target of template, the databases share
+// driver options and queries of template and differ by name and host
+func (p *Parser) addTemplate(tt cfg.DatabaseTemplateType) error {
+
+	if len(strings.TrimSpace(tt.Name)) == 0 {
+		return fmt.Errorf("Database template name is empty")
+	}
+
+	if len(tt.Targets) == 0 {
+		return fmt.Errorf("Database template `%+s` has no targets", tt.Name)
+	}
+
+	// host of each database is given by its target, so it cannot be overridden by driver options
+	opt := tt.DriverOption
+	if len(strings.TrimSpace(opt.Host)) > 0 || len(strings.TrimSpace(opt.HostFile)) > 0 ||
+		len(strings.TrimSpace(opt.HostEnv)) > 0 || len(opt.Hosts) > 0 {
+		return fmt.Errorf("Database template `%+s` cannot define host, host_file, host_env or hosts in driver options, hosts are given by targets", tt.Name)
+	}
+
+	for _, tgt := range tt.Targets {
+		targets, err := expandTarget(tt.Name, opt.Port, tgt)
+		if err != nil {
+			return fmt.Errorf("Database template `%+s` has invalid target, err=%v", tt.Name, err)
+		}
+
+		for _, t := range targets {
+			dt := tt.DatabasesType
+			dt.Name = t.name
+			dt.DriverOption.Host = t.host
+			dt.DriverOption.Port = t.port
+
+			if err := p.addDatabase(dt); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// expandTarget returns targets (name, host and port of database) generated from range or hosts file,
+// the placeholders in name and host are replaced with the number of range or the host; by default
+// the name of database is the name of template joined with host and the port is the one of template
+func expandTarget(templateName, port string, tt cfg.TargetType) ([]target, error) {
+	name := tt.Name
+	if len(strings.TrimSpace(name)) == 0 {
+		name = templateName + "_" + hostPlaceholder
+	}
+
+	hasRange := len(strings.TrimSpace(tt.Range)) > 0
+	hasFile := len(strings.TrimSpace(tt.HostsFile)) > 0
+
+	switch {
+	case hasRange && hasFile:
+		return nil, fmt.Errorf("range and hosts file cannot be combined")
+
+	case hasRange:
+		// without the placeholder each number of range would create the same database
+		if !strings.Contains(tt.Host, numberPlaceholder) {
+			return nil, fmt.Errorf("host `%s` has to contain %s placeholder replaced with numbers of range", tt.Host, numberPlaceholder)
+		}
+
+		numbers, err := parseRange(tt.Range)
+		if err != nil {
+			return nil, err
+		}
+
+		targets := make([]target, len(numbers))
+		for i, n := range numbers {
+			host := strings.Replace(tt.Host, numberPlaceholder, n, -1)
+			targets[i] = newTarget(strings.Replace(strings.Replace(name, numberPlaceholder, n, -1), hostPlaceholder, host, -1), host, port)
+		}
+		return targets, nil
+
+	case hasFile:
+		if len(strings.TrimSpace(tt.Host)) > 0 {
+			return nil, fmt.Errorf("host and hosts file cannot be combined")
+		}
+
+		hosts, err := readHostsFile(tt.HostsFile)
+		if err != nil {
+			return nil, err
+		}
+
+		targets := make([]target, len(hosts))
+		for i, host := range hosts {
+			targets[i] = newTarget(strings.Replace(name, hostPlaceholder, host, -1), host, port)
+		}
+		return targets, nil
+	}
+
+	return []target{newTarget(strings.Replace(name, hostPlaceholder, tt.Host, -1), tt.Host, port)}, nil
+}
+
+// newTarget returns target of database named `name`, the port given in host (e.g. "shard1:5433")
+// takes precedence over the default port
+func newTarget(name, host, port string) target {
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host = h
+		if p != "" {
+			port = p
+		}
+	}
+	return target{name: name, host: host, port: port}
+}
+
+// parseRange returns numbers of range given as "first-last", the numbers are padded with zeros
+// to the width of first number if it has leading zeros (e.g. "01-12")
+func parseRange(value string) ([]string, error) {
+	bounds := strings.Split(strings.TrimSpace(value), "-")
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("range `%s` has to be given as first-last", value)
+	}
+
+	first, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil || first < 0 {
+		return nil, fmt.Errorf("range `%s` has invalid first number", value)
+	}
+
+	last, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+	if err != nil || last < first {
+		return nil, fmt.Errorf("range `%s` has invalid last number", value)
+	}
+
+	if last-first >= maxRangeSize {
+		return nil, fmt.Errorf("range `%s` exceeds %d numbers", value, maxRangeSize)
+	}
+
+	width := 0
+	if b := strings.TrimSpace(bounds[0]); len(b) > 1 && strings.HasPrefix(b, "0") {
+		width = len(b)
+	}
+
+	numbers := make([]string, 0, last-first+1)
+	for n := first; n <= last; n++ {
+		numbers = append(numbers, fmt.Sprintf("%0*d", width, n))
+	}
+	return numbers, nil
+}
+
+// readHostsFile returns hosts listed in file `fName` (one per line), empty lines and comments (#) are skipped
+func readHostsFile(fName string) ([]string, error) {
+	f, err := os.Open(fName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hosts := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if host := strings.TrimSpace(line); len(host) > 0 {
+			hosts = append(hosts, host)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("hosts file `%s` is empty", fName)
+	}
+
+	return hosts, nil
+}
@@ -0,0 +1,119 @@
+// +build linux,small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/parser/cfg"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAddTemplate(t *testing.T) {
+
+	Convey("expanding database template", t, func() {
+		p := &Parser{qrs: map[string]*dtype.Query{}, dbs: map[string]*dtype.Database{}}
+
+		tt := cfg.DatabaseTemplateType{
+			DatabasesType: cfg.DatabasesType{
+				Name:           "shard",
+				Driver:         "mysql",
+				DriverOption:   cfg.DriverOptionType{Port: "3307", Username: "monitor"},
+				QueryToExecute: []cfg.DBQueryType{{QueryName: "orders"}},
+			},
+		}
+
+		Convey("creates database for each number of range", func() {
+			tt.Targets = []cfg.TargetType{{Name: "shard{n}", Host: "shard{n}.example.com", Range: "08-10"}}
+
+			So(p.addTemplate(tt), ShouldBeNil)
+			So(len(p.dbs), ShouldEqual, 3)
+			So(p.dbs, ShouldContainKey, "shard09")
+
+			db := p.dbs["shard10"]
+			So(db.Host, ShouldEqual, "shard10.example.com")
+			So(db.Port, ShouldEqual, "3307")
+			So(db.Username, ShouldEqual, "monitor")
+			So(db.QrsToExec, ShouldResemble, []string{"orders"})
+		})
+
+		Convey("creates database for each host read from file", func() {
+			f, err := ioutil.TempFile("", "dbi")
+			So(err, ShouldBeNil)
+			defer os.Remove(f.Name())
+
+			_, err = f.WriteString("# shards\n10.0.0.1\n\n10.0.0.2 # backup\n")
+			So(err, ShouldBeNil)
+			f.Close()
+
+			tt.Targets = []cfg.TargetType{{HostsFile: f.Name()}, {Name: "legacy", Host: "legacy.example.com"}}
+
+			So(p.addTemplate(tt), ShouldBeNil)
+			So(len(p.dbs), ShouldEqual, 3)
+			So(p.dbs["shard_10.0.0.2"].Host, ShouldEqual, "10.0.0.2")
+			So(p.dbs["legacy"].Host, ShouldEqual, "legacy.example.com")
+		})
+
+		Convey("uses port given in host of target", func() {
+			tt.Targets = []cfg.TargetType{{Name: "shard{n}", Host: "shard{n}:3310", Range: "1-2"}, {Name: "legacy", Host: "[fd00::1]:3311"}, {Name: "local", Host: "fd00::2"}}
+
+			So(p.addTemplate(tt), ShouldBeNil)
+			So(p.dbs["shard2"].Host, ShouldEqual, "shard2")
+			So(p.dbs["shard2"].Port, ShouldEqual, "3310")
+			So(p.dbs["legacy"].Host, ShouldEqual, "fd00::1")
+			So(p.dbs["legacy"].Port, ShouldEqual, "3311")
+			So(p.dbs["local"].Host, ShouldEqual, "fd00::2")
+			So(p.dbs["local"].Port, ShouldEqual, "3307")
+		})
+
+		Convey("fails when host of range has no number placeholder", func() {
+			tt.Targets = []cfg.TargetType{{Name: "shard{n}", Host: "shard.example.com", Range: "1-3"}}
+			So(p.addTemplate(tt), ShouldNotBeNil)
+		})
+
+		Convey("fails when names of databases are not unique", func() {
+			tt.Targets = []cfg.TargetType{{Name: "shard", Host: "shard{n}", Range: "1-2"}}
+			So(p.addTemplate(tt), ShouldNotBeNil)
+		})
+
+		Convey("fails when driver options define host", func() {
+			tt.Targets = []cfg.TargetType{{Host: "shard1"}}
+			options := []cfg.DriverOptionType{
+				{Host: "localhost"},
+				{HostFile: "/run/secrets/host"},
+				{HostEnv: "DB_HOST"},
+				{Hosts: []string{"shard1", "shard2"}},
+			}
+			for _, opt := range options {
+				tt.DriverOption = opt
+				So(p.addTemplate(tt), ShouldNotBeNil)
+			}
+			So(p.dbs, ShouldBeEmpty)
+		})
+
+		Convey("fails when range is invalid", func() {
+			for _, r := range []string{"1", "5-1", "a-3", "1-100000"} {
+				tt.Targets = []cfg.TargetType{{Host: "shard{n}", Range: r}}
+				So(p.addTemplate(tt), ShouldNotBeNil)
+			}
+		})
+	})
+}
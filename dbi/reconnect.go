@@ -0,0 +1,90 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbi
+
+import (
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+)
+
+// delays between reconnection attempts, the delay is doubled after each failed attempt
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 5 * time.Minute
+)
+
+// backoff holds the time of the next reconnection attempt to inactive database
+// and the delay applied when that attempt fails
+type backoff struct {
+	next  time.Time
+	delay time.Duration
+}
+
+// reconnectDBs tries to open inactive databases whose reconnection delay has elapsed,
+// so the collection self-heals when the connection to a database is restored
+func (dbiPlg *DbiPlugin) reconnectDBs() {
+	now := time.Now()
+
+	for dbName, db := range dbiPlg.databases {
+		if db.Active {
+			continue
+		}
+
+		b, exist := dbiPlg.backoffs[dbName]
+		if !exist {
+			b = &backoff{delay: minReconnectDelay}
+			dbiPlg.backoffs[dbName] = b
+		}
+
+		if now.Before(b.next) {
+			continue
+		}
+
+		if err := openDB(db); err != nil {
+			logf("Cannot reconnect to database %s, next attempt in %v, err=%v", dbName, b.delay, err)
+			b.next = now.Add(b.delay)
+			b.delay *= 2
+			if b.delay > maxReconnectDelay {
+				b.delay = maxReconnectDelay
+			}
+			continue
+		}
+
+		logf("Connection to database %s has been reestablished", dbName)
+		delete(dbiPlg.backoffs, dbName)
+	}
+}
+
+// checkConnection verifies the connection to database after failed query, if the connection is broken
+// the database is closed and marked as inactive, so reconnection will be attempted on the next collection
+func checkConnection(dbName string, db *dtype.Database) bool {
+	err := pingDB(db)
+	if err == nil {
+		return true
+	}
+
+	logf("Connection to database %s is broken, err=%v", dbName, err)
+
+	if err := closeDB(db); err != nil {
+		logf("Cannot close database %s, err=%v", dbName, err)
+	}
+	// the database is considered inactive even if closing failed
+	db.Active = false
+
+	return false
+}
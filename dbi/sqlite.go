@@ -0,0 +1,65 @@
+// +build linux,sqlite3
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbi
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/executor"
+)
+
+// init registers SQLite driver, it requires cgo so it is built in only with `sqlite3` build tag
+func init() {
+	RegisterDriver("sqlite3", Driver{DSN: sqliteDSN, Dialect: executor.SQLiteDialect()})
+}
+
+// sqliteDSN returns URI filename of SQLite database file with open-mode flags,
+// the database is opened read-only when mode is not defined
+func sqliteDSN(db *dtype.Database) (string, error) {
+	if isEmpty(db.Path) {
+		return "", errors.New("SQLite database requires a path to the database file")
+	}
+
+	params := url.Values{}
+	for k, v := range db.Params {
+		params.Set(k, v)
+	}
+
+	mode := db.Mode
+	if isEmpty(mode) {
+		mode = "ro"
+	}
+	if db.ReadOnly && mode != "ro" {
+		return "", fmt.Errorf("SQLite database cannot be opened in mode `%s` when read-only session is enabled", mode)
+	}
+	params.Set("mode", mode)
+
+	if db.Immutable {
+		// the database file cannot be changed, even by other processes
+		params.Set("immutable", "1")
+	}
+
+	// escape characters which have special meaning in URI (like `?` or `#`)
+	path := (&url.URL{Path: db.Path}).EscapedPath()
+
+	return "file:" + path + "?" + params.Encode(), nil
+}
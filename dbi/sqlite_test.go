@@ -0,0 +1,47 @@
+// +build linux,small,sqlite3
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbi
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSQLiteDSN(t *testing.T) {
+
+	Convey("creating SQLite data source name", t, func() {
+
+		Convey("for read-only database file", func() {
+			dsn, err := createDSN(&dtype.Database{Driver: "sqlite3", Path: "/var/lib/app #1.db", ReadOnly: true})
+			So(err, ShouldBeNil)
+			So(dsn, ShouldEqual, "file:/var/lib/app%20%231.db?mode=ro")
+		})
+
+		Convey("for writable mode with read-only session", func() {
+			_, err := createDSN(&dtype.Database{Driver: "sqlite3", Path: "/var/lib/app.db", Mode: "rw", ReadOnly: true})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("without path to database file", func() {
+			_, err := createDSN(&dtype.Database{Driver: "sqlite3"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
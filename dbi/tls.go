@@ -0,0 +1,185 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+)
+
+// TLS modes (the same as sslmode values of PostgreSQL)
+const (
+	tlsDisable    = "disable"
+	tlsRequire    = "require"
+	tlsVerifyCA   = "verify-ca"
+	tlsVerifyFull = "verify-full"
+)
+
+// getTLSMode returns TLS mode of database connection, if the mode is not given explicitly
+// it is determined on the basis of the other TLS options (TLS is disabled by default)
+func getTLSMode(opt dtype.TLS) (string, error) {
+	mode := opt.Mode
+
+	if isEmpty(mode) {
+		switch {
+		case opt.SkipVerify:
+			mode = tlsRequire
+		case isNotEmpty(opt.CA) || isNotEmpty(opt.Cert):
+			mode = tlsVerifyFull
+		default:
+			mode = tlsDisable
+		}
+	}
+
+	switch mode {
+	case tlsDisable, tlsRequire:
+	case tlsVerifyCA, tlsVerifyFull:
+		if opt.SkipVerify {
+			// server certificate is not verified at all
+			mode = tlsRequire
+		}
+	default:
+		return "", fmt.Errorf("TLS mode `%s` is not supported", opt.Mode)
+	}
+
+	return mode, nil
+}
+
+// postgresTLSParams returns lib/pq parameters of secure connection
+func postgresTLSParams(opt dtype.TLS) (map[string]string, error) {
+	mode, err := getTLSMode(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	if isNotEmpty(opt.ServerName) {
+		return nil, errors.New("TLS server name is not supported by postgres driver")
+	}
+
+	params := map[string]string{"sslmode": mode}
+
+	if isNotEmpty(opt.CA) {
+		params["sslrootcert"] = opt.CA
+	}
+	if isNotEmpty(opt.Cert) {
+		params["sslcert"] = opt.Cert
+	}
+	if isNotEmpty(opt.Key) {
+		params["sslkey"] = opt.Key
+	}
+
+	return params, nil
+}
+
+// registerMySQLTLS registers TLS config of database in go-sql-driver/mysql and returns the name under
+// which it was registered (the value of `tls` param in DSN), empty name means that TLS is disabled
+func registerMySQLTLS(db *dtype.Database) (string, error) {
+	mode, err := getTLSMode(db.TLS)
+	if err != nil || mode == tlsDisable {
+		return "", err
+	}
+
+	config, err := createTLSConfig(db.TLS, mode, db.Host)
+	if err != nil {
+		return "", err
+	}
+
+	// database name is hex encoded to avoid chars not allowed in DSN
+	name := fmt.Sprintf("dbi_%x", db.Name)
+	if err := mysql.RegisterTLSConfig(name, config); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// createTLSConfig returns TLS config for given TLS options and mode
+func createTLSConfig(opt dtype.TLS, mode, host string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: opt.ServerName}
+
+	if isEmpty(config.ServerName) {
+		config.ServerName = host
+	}
+
+	if isNotEmpty(opt.CA) {
+		pem, err := ioutil.ReadFile(opt.CA)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read TLS CA file, err=%v", err)
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Cannot parse any certificate from TLS CA file `%s`", opt.CA)
+		}
+	}
+
+	if isNotEmpty(opt.Cert) || isNotEmpty(opt.Key) {
+		cert, err := tls.LoadX509KeyPair(opt.Cert, opt.Key)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot load TLS client certificate, err=%v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	switch mode {
+	case tlsRequire:
+		config.InsecureSkipVerify = true
+
+	case tlsVerifyCA:
+		// verify certificate chain, but not the server name
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyCertChain(rawCerts, config.RootCAs)
+		}
+	}
+
+	return config, nil
+}
+
+// verifyCertChain verifies certificates presented by the server against root CAs (system roots when nil)
+func verifyCertChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("Server did not present any certificate")
+	}
+
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(opts)
+	return err
+}
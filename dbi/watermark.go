@@ -0,0 +1,285 @@
+// +build linux
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+Copyright 2016 Intel Corporation
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-collector-dbi/dbi/dtype"
+)
+
+// stateValue is a watermark persisted in state file, its type is kept so the value is bound
+// to statement in the same form as it has been returned by database
+type stateValue struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// watermark returns watermark of query `queryName` executed for database `dbName`, the initial one
+// is returned if the query has not been executed yet
+func (dbiPlg *DbiPlugin) watermark(queryName, dbName string, query *dtype.Query) interface{} {
+	values, loaded := dbiPlg.watermarks[queryName]
+	if !loaded {
+		values = map[string]interface{}{}
+		if query.Watermark.StateFile != "" {
+			var err error
+			if values, err = loadWatermarks(query.Watermark.StateFile); err != nil {
+				logf("Cannot load watermarks of query %s, initial value is used, err=%v", queryName, err)
+				values = map[string]interface{}{}
+			}
+		}
+		dbiPlg.watermarks[queryName] = values
+	}
+
+	if value, ok := values[dbName]; ok {
+		return value
+	}
+	return query.Watermark.Initial
+}
+
+// updateWatermark sets watermark of query `queryName` executed for database `dbName` to the maximum value
+// of watermark column in output `out` of query, the watermark is not changed when no rows are returned
+func (dbiPlg *DbiPlugin) updateWatermark(queryName, dbName string, query *dtype.Query, db *dtype.Database, out map[string][]interface{}) error {
+	column, ok := out[strings.ToLower(query.Watermark.Column)]
+	if !ok {
+		return fmt.Errorf("Query `%s` does not return watermark column `%s`", queryName, query.Watermark.Column)
+	}
+
+	current := dbiPlg.watermark(queryName, dbName, query)
+	max := current
+	for _, value := range column {
+		if value == nil {
+			// e.g. MAX(id) of no rows
+			continue
+		}
+
+		value = watermarkValue(convertValue(db, value))
+		if _, ok := compare(value, max); !ok {
+			return fmt.Errorf("Value `%v` of watermark column `%s` cannot be compared with watermark `%v`", value, query.Watermark.Column, max)
+		}
+		if greater(value, max) {
+			max = value
+		}
+	}
+
+	if max == current {
+		return nil
+	}
+
+	dbiPlg.watermarks[queryName][dbName] = max
+
+	if query.Watermark.StateFile != "" {
+		return saveWatermarks(query.Watermark.StateFile, dbiPlg.watermarks[queryName])
+	}
+	return nil
+}
+
+// watermarkValue returns value of watermark column converted in the same way as values of metrics,
+// but times are not converted to strings, so they can be bound to statement
+func watermarkValue(value interface{}) interface{} {
+	if t, ok := value.(time.Time); ok {
+		return t
+	}
+	return fixDataType(value)
+}
+
+// greater returns true if `a` is greater than `b`, false is returned also if values cannot be compared
+func greater(a, b interface{}) bool {
+	c, ok := compare(a, b)
+	return ok && c > 0
+}
+
+// compare returns -1, 0 or 1 if `a` is less than, equal to or greater than `b`; numbers of different types
+// and numbers given as strings (e.g. DECIMAL columns or initial value given in setfile as string) are compared
+// by their values, time is compared with string parsed as time; false is returned if values cannot be compared
+func compare(a, b interface{}) (int, bool) {
+	_, timeA := a.(time.Time)
+	_, timeB := b.(time.Time)
+	if timeA || timeB {
+		ta, okA := toTime(a)
+		tb, okB := toTime(b)
+		if !okA || !okB {
+			return 0, false
+		}
+		switch {
+		case ta.Before(tb):
+			return -1, true
+		case ta.After(tb):
+			return 1, true
+		}
+		return 0, true
+	}
+
+	ra, okA := toRat(a)
+	rb, okB := toRat(b)
+	if okA && okB {
+		return ra.Cmp(rb), true
+	}
+
+	if x, ok := a.(string); ok {
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	}
+
+	return 0, false
+}
+
+// timeLayouts are layouts of times given as strings, e.g. initial value of watermark given in setfile
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02"}
+
+// toTime converts time (also the one given as string, UTC is assumed if zone is not given) to time.Time;
+// false is returned if `value` is not a time
+func toTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// toRat converts number (also the one given as string) to big.Rat, so numbers of any type are compared
+// without loss of precision; false is returned if `value` is not a number
+func toRat(value interface{}) (*big.Rat, bool) {
+	switch v := value.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(v), true
+	case uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(v)), true
+	case float64:
+		// infinities and NaN cannot be converted
+		r := new(big.Rat).SetFloat64(v)
+		return r, r != nil
+	case string:
+		return new(big.Rat).SetString(strings.TrimSpace(v))
+	}
+	return nil, false
+}
+
+// loadWatermarks reads watermarks from state file, keys are names of databases; missing file
+// means that no watermark has been persisted yet
+func loadWatermarks(fName string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(fName)
+	if os.IsNotExist(err) {
+		return map[string]interface{}{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := map[string]stateValue{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("Invalid structure of state file `%s`, err=%v", fName, err)
+	}
+
+	values := map[string]interface{}{}
+	for dbName, sv := range state {
+		value, err := sv.decode()
+		if err != nil {
+			return nil, fmt.Errorf("Invalid watermark of database `%s` in state file `%s`, err=%v", dbName, fName, err)
+		}
+		values[dbName] = value
+	}
+
+	return values, nil
+}
+
+// saveWatermarks writes watermarks to state file, the file is replaced atomically
+// so it is not left truncated when writing fails
+func saveWatermarks(fName string, values map[string]interface{}) error {
+	state := map[string]stateValue{}
+	for dbName, value := range values {
+		sv, err := encodeWatermark(value)
+		if err != nil {
+			return err
+		}
+		state[dbName] = sv
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(fName), filepath.Base(fName))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fName)
+}
+
+// encodeWatermark returns watermark in the form persisted in state file
+func encodeWatermark(value interface{}) (stateValue, error) {
+	switch v := value.(type) {
+	case int64:
+		return stateValue{Type: "int", Value: strconv.FormatInt(v, 10)}, nil
+	case uint64:
+		return stateValue{Type: "uint", Value: strconv.FormatUint(v, 10)}, nil
+	case float64:
+		return stateValue{Type: "float", Value: strconv.FormatFloat(v, 'g', -1, 64)}, nil
+	case string:
+		return stateValue{Type: "string", Value: v}, nil
+	case time.Time:
+		return stateValue{Type: "time", Value: v.Format(time.RFC3339Nano)}, nil
+	case bool:
+		return stateValue{Type: "bool", Value: strconv.FormatBool(v)}, nil
+	}
+	return stateValue{}, fmt.Errorf("Watermark of type %T cannot be persisted", value)
+}
+
+// decode returns watermark persisted in state file
+func (sv stateValue) decode() (interface{}, error) {
+	switch sv.Type {
+	case "int":
+		return strconv.ParseInt(sv.Value, 10, 64)
+	case "uint":
+		return strconv.ParseUint(sv.Value, 10, 64)
+	case "float":
+		return strconv.ParseFloat(sv.Value, 64)
+	case "string":
+		return sv.Value, nil
+	case "time":
+		return time.Parse(time.RFC3339Nano, sv.Value)
+	case "bool":
+		return strconv.ParseBool(sv.Value)
+	}
+	return nil, fmt.Errorf("unknown type `%s`", sv.Type)
+}